@@ -0,0 +1,263 @@
+package main
+
+import (
+	"crypto/md5"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"hash"
+	"net/http"
+	"regexp"
+	"strings"
+	"sync"
+)
+
+// AuthType selects how a request authenticates against the server
+type AuthType string
+
+const (
+	AuthNone   AuthType = ""       // No authentication
+	AuthDigest AuthType = "digest" // HTTP Digest authentication (RFC 7616)
+	AuthJWT    AuthType = "jwt"    // Self-signed JWT sent as bearer token
+)
+
+var authTypes = []AuthType{AuthNone, AuthDigest, AuthJWT}
+
+// String returns the display name of the auth type
+func (t AuthType) String() string {
+	switch t {
+	case AuthDigest:
+		return "Digest"
+	case AuthJWT:
+		return "JWT"
+	default:
+		return "None"
+	}
+}
+
+// AuthConfig holds the authentication settings of a request
+type AuthConfig struct {
+	Type     AuthType   `json:"type"`
+	Username string     `json:"username,omitempty"`
+	Password string     `json:"password,omitempty"`
+	JWT      *JWTConfig `json:"jwt,omitempty"` // Only for AuthJWT
+}
+
+// digestChallenge is a parsed "WWW-Authenticate: Digest ..." challenge
+type digestChallenge struct {
+	Realm     string
+	Nonce     string
+	Opaque    string
+	Algorithm string
+	Qop       string
+	Stale     bool
+}
+
+// digestSession remembers the last challenge of a request so that following
+// sends can authorize preemptively with an incremented nonce count
+type digestSession struct {
+	mu         sync.Mutex
+	challenge  *digestChallenge
+	nonceCount uint32
+}
+
+// authChallenge is a challenge of a WWW-Authenticate header, e.g. `Digest realm="api", nonce="abc"`
+type authChallenge struct {
+	Scheme string            // Auth scheme in lower case, e.g. "digest"
+	Params map[string]string // Auth params by lower case name
+}
+
+// authToken68 matches the token68 credentials of a challenge up to the next challenge
+var authToken68 = regexp.MustCompile(`^[A-Za-z0-9\-._~+/]+=*[ \t]*(,|$)`)
+
+// parseAuthChallenges splits a WWW-Authenticate header value into its challenges (RFC 7235).
+// A token not followed by "=" starts a new challenge, anything else is a parameter of the
+// current one; token68 credentials such as "Bearer abc==" are skipped.
+func parseAuthChallenges(value string) []authChallenge {
+	var challenges []authChallenge
+	input := value
+	for {
+		input = strings.TrimLeft(input, " \t,")
+		if input == "" {
+			return challenges
+		}
+		end := strings.IndexAny(input, " \t,=")
+		if end < 0 {
+			end = len(input)
+		}
+		if rest := strings.TrimLeft(input[end:], " \t"); len(challenges) == 0 || !strings.HasPrefix(rest, "=") {
+			challenges = append(challenges, authChallenge{Scheme: strings.ToLower(input[:end]), Params: map[string]string{}})
+			input = input[end:]
+			continue
+		}
+		current := challenges[len(challenges)-1]
+		if token := authToken68.FindString(input); token != "" && len(current.Params) == 0 {
+			input = input[len(token):]
+			continue
+		}
+		var key, paramValue string
+		key, paramValue, input = parseAuthParam(input)
+		if key != "" {
+			current.Params[key] = paramValue
+		}
+	}
+}
+
+// parseAuthParam parses the auth-param at the start of the input (key=value or key="quoted value")
+// and returns the rest of the input
+func parseAuthParam(input string) (key, value, rest string) {
+	eq := strings.IndexByte(input, '=')
+	key = strings.ToLower(strings.TrimSpace(input[:eq]))
+	input = strings.TrimLeft(input[eq+1:], " \t")
+
+	var builder strings.Builder
+	if strings.HasPrefix(input, `"`) {
+		i := 1
+		for ; i < len(input) && input[i] != '"'; i++ {
+			if input[i] == '\\' && i+1 < len(input) {
+				i++
+			}
+			builder.WriteByte(input[i])
+		}
+		return key, builder.String(), input[min(i+1, len(input)):]
+	}
+	end := strings.IndexByte(input, ',')
+	if end < 0 {
+		end = len(input)
+	}
+	return key, strings.TrimSpace(input[:end]), input[end:]
+}
+
+// parseDigestChallenge picks the strongest supported Digest challenge from the response headers
+func parseDigestChallenge(header http.Header) (*digestChallenge, error) {
+	var best *digestChallenge
+	for _, value := range header.Values("WWW-Authenticate") {
+		// A header value may carry several challenges; only Digest ones are of interest
+		for _, offered := range parseAuthChallenges(value) {
+			if offered.Scheme != "digest" {
+				continue
+			}
+			params := offered.Params
+			challenge := &digestChallenge{
+				Realm:     params["realm"],
+				Nonce:     params["nonce"],
+				Opaque:    params["opaque"],
+				Algorithm: strings.ToUpper(params["algorithm"]),
+				Stale:     strings.EqualFold(params["stale"], "true"),
+			}
+			if challenge.Algorithm == "" {
+				challenge.Algorithm = "MD5"
+			}
+			if digestHash(challenge.Algorithm) == nil || challenge.Nonce == "" {
+				continue
+			}
+			if qop, ok := params["qop"]; ok {
+				for option := range strings.SplitSeq(qop, ",") {
+					if strings.TrimSpace(option) == "auth" {
+						challenge.Qop = "auth"
+					}
+				}
+				if challenge.Qop == "" {
+					continue // only auth-int offered, which is not supported
+				}
+			}
+			if best == nil || strings.HasPrefix(challenge.Algorithm, "SHA-256") {
+				best = challenge
+			}
+		}
+	}
+	if best == nil {
+		return nil, fmt.Errorf("server did not offer a supported Digest challenge")
+	}
+	return best, nil
+}
+
+// digestSessionsMu guards the creation of the Digest sessions of requests, which
+// concurrent sends of the same request would otherwise race for
+var digestSessionsMu sync.Mutex
+
+// digestSession returns the Digest session of the request, created on first use
+func (request *Request) digestSession() *digestSession {
+	digestSessionsMu.Lock()
+	defer digestSessionsMu.Unlock()
+	if request.digest == nil {
+		request.digest = &digestSession{}
+	}
+	return request.digest
+}
+
+// digestHash returns a hash constructor for the given Digest algorithm, or nil if unsupported
+func digestHash(algorithm string) func() hash.Hash {
+	switch strings.TrimSuffix(algorithm, "-SESS") {
+	case "MD5":
+		return md5.New
+	case "SHA-256":
+		return sha256.New
+	}
+	return nil
+}
+
+// authorize computes the Authorization header for the given request using the current challenge
+func (session *digestSession) authorize(req *http.Request, username, password string) (string, error) {
+	session.mu.Lock()
+	defer session.mu.Unlock()
+
+	challenge := session.challenge
+	newHash := digestHash(challenge.Algorithm)
+	h := func(data string) string {
+		hasher := newHash()
+		hasher.Write([]byte(data))
+		return hex.EncodeToString(hasher.Sum(nil))
+	}
+
+	cnonceBytes := make([]byte, 16)
+	if _, err := rand.Read(cnonceBytes); err != nil {
+		return "", err
+	}
+	cnonce := hex.EncodeToString(cnonceBytes)
+	session.nonceCount++
+	nc := fmt.Sprintf("%08x", session.nonceCount)
+	uri := req.URL.RequestURI()
+
+	ha1 := h(username + ":" + challenge.Realm + ":" + password)
+	if strings.HasSuffix(challenge.Algorithm, "-SESS") {
+		ha1 = h(ha1 + ":" + challenge.Nonce + ":" + cnonce)
+	}
+	ha2 := h(req.Method + ":" + uri)
+
+	var response string
+	if challenge.Qop != "" {
+		response = h(ha1 + ":" + challenge.Nonce + ":" + nc + ":" + cnonce + ":" + challenge.Qop + ":" + ha2)
+	} else {
+		response = h(ha1 + ":" + challenge.Nonce + ":" + ha2)
+	}
+
+	var builder strings.Builder
+	fmt.Fprintf(&builder, `Digest username=%q, realm=%q, nonce=%q, uri=%q, algorithm=%s, response=%q`,
+		username, challenge.Realm, challenge.Nonce, uri, challenge.Algorithm, response)
+	if challenge.Qop != "" {
+		fmt.Fprintf(&builder, `, qop=%s, nc=%s, cnonce=%q`, challenge.Qop, nc, cnonce)
+	}
+	if challenge.Opaque != "" {
+		fmt.Fprintf(&builder, `, opaque=%q`, challenge.Opaque)
+	}
+	return builder.String(), nil
+}
+
+// update replaces the remembered challenge and resets the nonce count for a new nonce
+func (session *digestSession) update(challenge *digestChallenge) {
+	session.mu.Lock()
+	defer session.mu.Unlock()
+	if session.challenge == nil || session.challenge.Nonce != challenge.Nonce {
+		session.nonceCount = 0
+	}
+	session.challenge = challenge
+}
+
+// hasChallenge reports whether a previous challenge can be used for preemptive authorization
+func (session *digestSession) hasChallenge() bool {
+	session.mu.Lock()
+	defer session.mu.Unlock()
+	return session.challenge != nil
+}
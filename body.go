@@ -0,0 +1,352 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net/textproto"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// BodyMode selects how the request body is built
+type BodyMode string
+
+const (
+	BodyRaw       BodyMode = ""          // Body text as entered
+	BodyForm      BodyMode = "form"      // application/x-www-form-urlencoded from FormFields
+	BodyMultipart BodyMode = "multipart" // multipart/form-data from FormFields, with file parts
+	BodyFile      BodyMode = "file"      // Content of BodyFile, streamed from disk
+	BodyGraphQL   BodyMode = "graphql"   // JSON envelope built from GraphQL
+)
+
+var bodyModes = []BodyMode{BodyRaw, BodyForm, BodyMultipart, BodyFile, BodyGraphQL}
+
+// String returns the display name of the body mode
+func (m BodyMode) String() string {
+	switch m {
+	case BodyForm:
+		return "Form (urlencoded)"
+	case BodyMultipart:
+		return "Multipart form-data"
+	case BodyFile:
+		return "Binary file"
+	case BodyGraphQL:
+		return "GraphQL"
+	default:
+		return "Raw"
+	}
+}
+
+// FormField is a field of a form-urlencoded or multipart body
+type FormField struct {
+	Name        string `json:"name"`
+	Value       string `json:"value,omitempty"`       // Text value
+	File        string `json:"file,omitempty"`        // Multipart only: file to upload, relative to the project file
+	FileName    string `json:"fileName,omitempty"`    // File name of the part, defaults to the base name of File
+	ContentType string `json:"contentType,omitempty"` // Content type of the file part, derived from the extension if empty
+	Disabled    bool   `json:"disabled,omitempty"`    // Kept in the list but not sent
+}
+
+// FormatFormFields renders one "name: value" line per field. File fields are
+// written as "name: @path;filename=name;type=content/type", disabled fields with a '#' prefix.
+func FormatFormFields(fields []FormField) string {
+	var builder strings.Builder
+	for _, field := range fields {
+		if field.Disabled {
+			builder.WriteString("# ")
+		}
+		builder.WriteString(field.Name)
+		builder.WriteString(": ")
+		if field.File != "" {
+			builder.WriteString("@")
+			builder.WriteString(field.File)
+			if field.FileName != "" {
+				builder.WriteString(";filename=")
+				builder.WriteString(field.FileName)
+			}
+			if field.ContentType != "" {
+				builder.WriteString(";type=")
+				builder.WriteString(field.ContentType)
+			}
+		} else {
+			builder.WriteString(field.Value)
+		}
+		builder.WriteString("\r\n")
+	}
+	return builder.String()
+}
+
+// ParseFormFields parses the lines written by FormatFormFields
+func ParseFormFields(input string) []FormField {
+	var fields []FormField
+	for _, param := range ParseParams(input) {
+		field := FormField{Name: param.Name, Value: param.Value, Disabled: param.Disabled}
+		if file, ok := strings.CutPrefix(param.Value, "@"); ok {
+			parts := strings.Split(file, ";")
+			field.Value = ""
+			field.File = strings.TrimSpace(parts[0])
+			for _, option := range parts[1:] {
+				key, value, _ := strings.Cut(option, "=")
+				switch strings.ToLower(strings.TrimSpace(key)) {
+				case "filename":
+					field.FileName = strings.TrimSpace(value)
+				case "type":
+					field.ContentType = strings.TrimSpace(value)
+				}
+			}
+		}
+		fields = append(fields, field)
+	}
+	return fields
+}
+
+// bodySource produces the request body; open is called once per round trip
+type bodySource struct {
+	contentType  string // Content type to send, empty to keep the configured header
+	fallbackType string // Content type to send if no Content-Type header is configured
+	length       int64  // Content length, -1 if unknown
+	open         func() (io.ReadCloser, error)
+}
+
+// resolvePath makes a path relative to the project directory absolute
+func resolvePath(baseDir, path string) string {
+	if path == "" || filepath.IsAbs(path) || baseDir == "" {
+		return path
+	}
+	return filepath.Join(baseDir, path)
+}
+
+// bodySource builds the body of the request according to its body mode.
+// Relative file paths are resolved against baseDir.
+func (request *Request) bodySource(baseDir string) (*bodySource, error) {
+	switch request.BodyMode {
+	case BodyForm:
+		values := Params{}
+		for _, field := range request.FormFields {
+			// File parts only exist in multipart bodies
+			if !field.Disabled && field.File == "" {
+				values = append(values, Param{Name: field.Name, Value: field.Value})
+			}
+		}
+		return bytesBodySource([]byte(values.EncodeQuery(QueryEncodingForm)), "application/x-www-form-urlencoded"), nil
+	case BodyMultipart:
+		return multipartBodySource(request.FormFields, baseDir)
+	case BodyFile:
+		return fileBodySource(request.BodyFile, baseDir)
+	case BodyGraphQL:
+		if request.GraphQL == nil {
+			return nil, fmt.Errorf("no GraphQL query")
+		}
+		data, err := request.GraphQL.envelope()
+		if err != nil {
+			return nil, err
+		}
+		return bytesBodySource(data, "application/json"), nil
+	default:
+		return bytesBodySource([]byte(request.Body), ""), nil
+	}
+}
+
+// fileBodySource streams a file from disk as body
+func fileBodySource(file, baseDir string) (*bodySource, error) {
+	if strings.TrimSpace(file) == "" {
+		return nil, fmt.Errorf("no body file selected")
+	}
+	path := resolvePath(baseDir, strings.TrimSpace(file))
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, fmt.Errorf("body file: %v", err)
+	}
+	if info.IsDir() {
+		return nil, fmt.Errorf("body file %s is a directory", path)
+	}
+	fallbackType := mime.TypeByExtension(filepath.Ext(path))
+	if fallbackType == "" {
+		fallbackType = "application/octet-stream"
+	}
+	return &bodySource{
+		fallbackType: fallbackType,
+		length:       info.Size(),
+		open: func() (io.ReadCloser, error) {
+			return os.Open(path)
+		},
+	}, nil
+}
+
+func bytesBodySource(data []byte, contentType string) *bodySource {
+	return &bodySource{
+		contentType: contentType,
+		length:      int64(len(data)),
+		open: func() (io.ReadCloser, error) {
+			return io.NopCloser(bytes.NewReader(data)), nil
+		},
+	}
+}
+
+// multipartBodySource lays out a multipart/form-data body whose file parts are
+// streamed from disk, so that the Content-Length is known without loading the files
+func multipartBodySource(fields []FormField, baseDir string) (*bodySource, error) {
+	var segments [][]byte // Generated multipart framing around the file contents
+	var files []string    // File following segments[i], empty after the last segment
+	var buffer bytes.Buffer
+	writer := multipart.NewWriter(&buffer)
+	length := int64(0)
+
+	for _, field := range fields {
+		if field.Disabled {
+			continue
+		}
+		if field.File == "" {
+			if err := writer.WriteField(field.Name, field.Value); err != nil {
+				return nil, err
+			}
+			continue
+		}
+
+		path := resolvePath(baseDir, field.File)
+		info, err := os.Stat(path)
+		if err != nil {
+			return nil, fmt.Errorf("form field %q: %v", field.Name, err)
+		}
+		fileName := field.FileName
+		if fileName == "" {
+			fileName = filepath.Base(path)
+		}
+		contentType := field.ContentType
+		if contentType == "" {
+			contentType = mime.TypeByExtension(filepath.Ext(path))
+		}
+		if contentType == "" {
+			contentType = "application/octet-stream"
+		}
+
+		header := make(textproto.MIMEHeader)
+		header.Set("Content-Disposition", mime.FormatMediaType("form-data", map[string]string{"name": field.Name, "filename": fileName}))
+		header.Set("Content-Type", contentType)
+		if _, err := writer.CreatePart(header); err != nil {
+			return nil, err
+		}
+		segments = append(segments, bytes.Clone(buffer.Bytes()))
+		files = append(files, path)
+		length += int64(buffer.Len()) + info.Size()
+		buffer.Reset()
+	}
+	if err := writer.Close(); err != nil {
+		return nil, err
+	}
+	segments = append(segments, bytes.Clone(buffer.Bytes()))
+	files = append(files, "")
+	length += int64(buffer.Len())
+
+	return &bodySource{
+		contentType: writer.FormDataContentType(),
+		length:      length,
+		open: func() (io.ReadCloser, error) {
+			return &multipartReader{segments: segments, files: files}, nil
+		},
+	}, nil
+}
+
+// multipartReader alternates the framing segments with the file contents, opening each file when reached
+type multipartReader struct {
+	segments [][]byte
+	files    []string // files[i] follows segments[i]
+	next     int      // Index of the next segment
+	current  io.Reader
+	file     *os.File
+}
+
+func (r *multipartReader) Read(p []byte) (int, error) {
+	for {
+		if r.current != nil {
+			n, err := r.current.Read(p)
+			if n > 0 {
+				return n, nil
+			}
+			if err != io.EOF {
+				return 0, err
+			}
+			r.current = nil
+			if r.file != nil {
+				r.file.Close()
+				r.file = nil
+				continue
+			}
+			// Segment finished, continue with the file that follows it
+			if path := r.files[r.next-1]; path != "" {
+				file, err := os.Open(path)
+				if err != nil {
+					return 0, err
+				}
+				r.file = file
+				r.current = file
+			}
+			continue
+		}
+		if r.next >= len(r.segments) {
+			return 0, io.EOF
+		}
+		r.current = bytes.NewReader(r.segments[r.next])
+		r.next++
+	}
+}
+
+func (r *multipartReader) Close() error {
+	if r.file != nil {
+		return r.file.Close()
+	}
+	return nil
+}
+
+// progressReader reports the number of bytes read from the wrapped body
+type progressReader struct {
+	io.ReadCloser
+	sent       int64
+	total      int64
+	lastReport time.Time
+	onProgress func(sent, total int64)
+}
+
+func (r *progressReader) Read(p []byte) (int, error) {
+	n, err := r.ReadCloser.Read(p)
+	r.sent += int64(n)
+	// Throttle reports so that the UI is not flooded, but always report the end
+	if err == io.EOF || time.Since(r.lastReport) >= 100*time.Millisecond {
+		r.lastReport = time.Now()
+		r.onProgress(r.sent, r.total)
+	}
+	return n, err
+}
+
+// withProgress wraps the body source so that every opened body reports its upload progress
+func (source *bodySource) withProgress(onProgress func(sent, total int64)) *bodySource {
+	open := source.open
+	wrapped := *source
+	wrapped.open = func() (io.ReadCloser, error) {
+		body, err := open()
+		if err != nil {
+			return nil, err
+		}
+		return &progressReader{ReadCloser: body, total: source.length, onProgress: onProgress}, nil
+	}
+	return &wrapped
+}
+
+// formatSize formats a byte count for display, e.g. "1.5 MB"
+func formatSize(size int64) string {
+	const unit = 1024
+	if size < unit {
+		return fmt.Sprintf("%d B", size)
+	}
+	div, exp := int64(unit), 0
+	for n := size / unit; n >= unit; n /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %cB", float64(size)/float64(div), "KMGTPE"[exp])
+}
@@ -0,0 +1,143 @@
+package main
+
+import (
+	"bytes"
+	"mime"
+	"regexp"
+	"strings"
+	"unicode/utf16"
+	"unicode/utf8"
+)
+
+// charsets are the choices of the charset override, "" detects the charset of the response
+var charsets = []string{"", "utf-8", "iso-8859-1", "windows-1252", "utf-16le", "utf-16be"}
+
+// charsetLabel returns the display name of a charset choice
+func charsetLabel(charset string) string {
+	if charset == "" {
+		return "Auto charset"
+	}
+	return strings.ToUpper(charset)
+}
+
+// charsetAliases maps common labels to the charsets that can be decoded
+var charsetAliases = map[string]string{
+	"utf8":         "utf-8",
+	"us-ascii":     "utf-8",
+	"ascii":        "utf-8",
+	"latin1":       "iso-8859-1",
+	"latin-1":      "iso-8859-1",
+	"iso8859-1":    "iso-8859-1",
+	"iso_8859-1":   "iso-8859-1",
+	"l1":           "iso-8859-1",
+	"cp1252":       "windows-1252",
+	"x-cp1252":     "windows-1252",
+	"utf-16":       "utf-16be", // Without BOM, big endian is the default
+	"unicodefffe":  "utf-16be",
+	"unicode":      "utf-16le",
+	"utf-16-le":    "utf-16le",
+	"utf-16-be":    "utf-16be",
+	"ucs-2":        "utf-16le",
+	"windows-1200": "utf-16le",
+}
+
+// normalizeCharset returns the canonical name of a charset label
+func normalizeCharset(label string) string {
+	label = strings.ToLower(strings.Trim(strings.TrimSpace(label), `"'`))
+	if alias, ok := charsetAliases[label]; ok {
+		return alias
+	}
+	return label
+}
+
+var (
+	xmlEncodingPattern = regexp.MustCompile(`^<\?xml[^>]*\sencoding\s*=\s*["']([A-Za-z0-9._:-]+)["']`)
+	htmlCharsetPattern = regexp.MustCompile(`(?i)<meta[^>]+charset\s*=\s*["']?([A-Za-z0-9._:-]+)`)
+)
+
+// detectCharset determines the charset of a text body. An override wins, followed
+// by a byte order mark, the charset of the Content-Type, the encoding of the XML
+// prolog and the HTML meta charset. It returns the length of a BOM to skip.
+func detectCharset(data []byte, contentType, override string) (string, int) {
+	bom := 0
+	charset := ""
+	switch {
+	case bytes.HasPrefix(data, []byte{0xEF, 0xBB, 0xBF}):
+		charset, bom = "utf-8", 3
+	case bytes.HasPrefix(data, []byte{0xFF, 0xFE}):
+		charset, bom = "utf-16le", 2
+	case bytes.HasPrefix(data, []byte{0xFE, 0xFF}):
+		charset, bom = "utf-16be", 2
+	}
+	if override != "" {
+		override = normalizeCharset(override)
+		if override != charset {
+			bom = 0
+		}
+		return override, bom
+	}
+	if charset != "" {
+		return charset, bom
+	}
+	if _, params, err := mime.ParseMediaType(contentType); err == nil && params["charset"] != "" {
+		return normalizeCharset(params["charset"]), 0
+	}
+	head := data[:min(len(data), 1024)]
+	if match := xmlEncodingPattern.FindSubmatch(head); match != nil {
+		return normalizeCharset(string(match[1])), 0
+	}
+	if match := htmlCharsetPattern.FindSubmatch(head); match != nil {
+		return normalizeCharset(string(match[1])), 0
+	}
+	return "utf-8", 0
+}
+
+// windows1252 holds the characters of 0x80 to 0x9F, which differ from ISO-8859-1.
+// Unassigned bytes map to the C1 control of the same value.
+var windows1252 = [32]rune{
+	'€', 0x81, '‚', 'ƒ', '„', '…', '†', '‡', 'ˆ', '‰', 'Š', '‹', 'Œ', 0x8D, 'Ž', 0x8F,
+	0x90, '‘', '’', '“', '”', '•', '–', '—', '˜', '™', 'š', '›', 'œ', 0x9D, 'ž', 'Ÿ',
+}
+
+// decodeCharset converts data in the charset to a string.
+// It returns false if the charset is not supported.
+func decodeCharset(data []byte, charset string) (string, bool) {
+	switch charset {
+	case "utf-8":
+		return string(data), true
+	case "iso-8859-1", "windows-1252":
+		var builder strings.Builder
+		builder.Grow(len(data) + len(data)/8)
+		for _, b := range data {
+			if charset == "windows-1252" && b >= 0x80 && b <= 0x9F {
+				builder.WriteRune(windows1252[b-0x80])
+			} else if b < utf8.RuneSelf {
+				builder.WriteByte(b)
+			} else {
+				builder.WriteRune(rune(b))
+			}
+		}
+		return builder.String(), true
+	case "utf-16le", "utf-16be":
+		units := make([]uint16, len(data)/2)
+		for i := range units {
+			if charset == "utf-16le" {
+				units[i] = uint16(data[2*i]) | uint16(data[2*i+1])<<8
+			} else {
+				units[i] = uint16(data[2*i])<<8 | uint16(data[2*i+1])
+			}
+		}
+		return string(utf16.Decode(units)), true
+	}
+	return "", false
+}
+
+// decodeText decodes a text body and returns the charset it was decoded with.
+// Unsupported charsets are shown as UTF-8.
+func decodeText(data []byte, contentType, override string) (string, string) {
+	charset, bom := detectCharset(data, contentType, override)
+	if text, ok := decodeCharset(data[bom:], charset); ok {
+		return text, charset
+	}
+	return string(data), charset + " (unsupported, shown as UTF-8)"
+}
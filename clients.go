@@ -0,0 +1,101 @@
+package main
+
+import (
+	"net/http"
+	"strings"
+	"sync"
+)
+
+// maxCachedClients limits the cached clients; the least recently used one is dropped beyond it
+const maxCachedClients = 16
+
+// clientCache keeps HTTP clients between sends so that keep-alive connections
+// and TLS sessions are reused instead of paying a full handshake every time
+type clientCache struct {
+	mu      sync.Mutex
+	clients map[clientKey]*cachedClient
+	uses    uint64 // Counts the uses to find the least recently used client
+}
+
+// cachedClient is a cached client with the count of its last use
+type cachedClient struct {
+	client  *http.Client
+	lastUse uint64
+}
+
+// clientKey is the effective configuration a cached client was built for
+type clientKey struct {
+	certFile    string
+	keyFile     string
+	caCertFile  string
+	skipVerify  bool
+	proxy       ProxyConfig
+	timeoutInMs int64
+	jar         http.CookieJar
+}
+
+// httpClient returns a client for the given send configuration, reusing a cached one if possible.
+// With fresh set, a new client is built that neither reuses nor keeps connections.
+func (settings *Settings) httpClient(env *Environment, timeoutInMs int64, jar http.CookieJar, fresh bool) (*http.Client, error) {
+	if fresh {
+		client, err := createHTTPClient(settings, env, timeoutInMs)
+		if err != nil {
+			return nil, err
+		}
+		client.Transport.(*http.Transport).DisableKeepAlives = true
+		client.Jar = jar
+		return client, nil
+	}
+
+	key := clientKey{
+		certFile:    strings.TrimSpace(settings.Certificate.CertFile),
+		keyFile:     strings.TrimSpace(settings.Certificate.KeyFile),
+		caCertFile:  settings.Certificate.CACertFile,
+		skipVerify:  settings.Certificate.SkipVerify,
+		proxy:       effectiveProxy(settings, env),
+		timeoutInMs: timeoutInMs,
+		jar:         jar,
+	}
+
+	cache := &settings.clients
+	cache.mu.Lock()
+	defer cache.mu.Unlock()
+	cache.uses++
+	if cached, ok := cache.clients[key]; ok {
+		cached.lastUse = cache.uses
+		return cached.client, nil
+	}
+	client, err := createHTTPClient(settings, env, timeoutInMs)
+	if err != nil {
+		return nil, err
+	}
+	client.Jar = jar
+	if cache.clients == nil {
+		cache.clients = make(map[clientKey]*cachedClient)
+	}
+	if len(cache.clients) >= maxCachedClients {
+		// Replaced configurations, e.g. an edited timeout, are the least recently used ones
+		var oldest clientKey
+		var oldestUse uint64
+		for key, cached := range cache.clients {
+			if oldestUse == 0 || cached.lastUse < oldestUse {
+				oldest, oldestUse = key, cached.lastUse
+			}
+		}
+		cache.clients[oldest].client.CloseIdleConnections()
+		delete(cache.clients, oldest)
+	}
+	cache.clients[key] = &cachedClient{client: client, lastUse: cache.uses}
+	return client, nil
+}
+
+// invalidateClients drops all cached clients, e.g. after the certificate files,
+// the timeout or a proxy of the project changed
+func (settings *Settings) invalidateClients() {
+	settings.clients.mu.Lock()
+	defer settings.clients.mu.Unlock()
+	for _, cached := range settings.clients.clients {
+		cached.client.CloseIdleConnections()
+	}
+	settings.clients.clients = nil
+}
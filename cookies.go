@@ -0,0 +1,234 @@
+package main
+
+import (
+	"net"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+// CookiePersistence selects where the cookies of a project are kept between app runs
+type CookiePersistence string
+
+const (
+	CookiePersistNone     CookiePersistence = ""         // Cookies live only while the app runs
+	CookiePersistProject  CookiePersistence = "project"  // Cookies are saved in the .rtp file
+	CookiePersistSettings CookiePersistence = "settings" // Cookies are saved in the user settings
+)
+
+var cookiePersistenceModes = []CookiePersistence{CookiePersistNone, CookiePersistProject, CookiePersistSettings}
+
+// String returns the display name of the persistence mode
+func (c CookiePersistence) String() string {
+	switch c {
+	case CookiePersistProject:
+		return "Persist in project"
+	case CookiePersistSettings:
+		return "Persist in settings"
+	default:
+		return "Don't persist"
+	}
+}
+
+// StoredCookie is a cookie as kept by the CookieJar
+type StoredCookie struct {
+	Name     string    `json:"name"`
+	Value    string    `json:"value"`
+	Domain   string    `json:"domain"`             // Domain without leading dot
+	Path     string    `json:"path"`               // Path prefix the cookie applies to
+	HostOnly bool      `json:"hostOnly,omitempty"` // Only sent to exactly Domain, not to subdomains
+	Secure   bool      `json:"secure,omitempty"`
+	HttpOnly bool      `json:"httpOnly,omitempty"`
+	Expires  time.Time `json:"expires,omitzero"` // Zero for session cookies
+}
+
+// CookieJar is an http.CookieJar whose content can be listed, edited and persisted
+type CookieJar struct {
+	mu      sync.Mutex
+	cookies []StoredCookie
+	changed bool // The cookies changed since they were last persisted
+}
+
+// NewCookieJar creates a jar seeded with the given cookies
+func NewCookieJar(cookies []StoredCookie) *CookieJar {
+	return &CookieJar{cookies: slices.Clone(cookies)}
+}
+
+// SetCookies implements http.CookieJar
+func (jar *CookieJar) SetCookies(u *url.URL, cookies []*http.Cookie) {
+	jar.mu.Lock()
+	defer jar.mu.Unlock()
+
+	host := canonicalCookieHost(u.Hostname())
+	now := time.Now()
+	for _, cookie := range cookies {
+		stored := StoredCookie{
+			Name:     cookie.Name,
+			Value:    cookie.Value,
+			Domain:   host,
+			Path:     cookie.Path,
+			HostOnly: true,
+			Secure:   cookie.Secure,
+			HttpOnly: cookie.HttpOnly,
+		}
+		if domain := canonicalCookieHost(strings.TrimPrefix(cookie.Domain, ".")); domain != "" {
+			// Reject cookies for domains the responding host does not belong to
+			if !domainMatch(host, domain) {
+				continue
+			}
+			stored.Domain = domain
+			stored.HostOnly = false
+		}
+		if stored.Path == "" || !strings.HasPrefix(stored.Path, "/") {
+			stored.Path = defaultCookiePath(u.Path)
+		}
+
+		expired := false
+		switch {
+		case cookie.MaxAge < 0:
+			expired = true
+		case cookie.MaxAge > 0:
+			stored.Expires = now.Add(time.Duration(cookie.MaxAge) * time.Second)
+		case !cookie.Expires.IsZero():
+			stored.Expires = cookie.Expires
+			expired = !cookie.Expires.After(now)
+		}
+
+		index := jar.indexOf(stored.Name, stored.Domain, stored.Path)
+		switch {
+		case expired && index >= 0:
+			jar.cookies = slices.Delete(jar.cookies, index, index+1)
+			jar.changed = true
+		case expired:
+		case index >= 0:
+			// Servers often resend unchanged cookies
+			jar.changed = jar.changed || jar.cookies[index] != stored
+			jar.cookies[index] = stored
+		default:
+			jar.cookies = append(jar.cookies, stored)
+			jar.changed = true
+		}
+	}
+}
+
+// Cookies implements http.CookieJar
+func (jar *CookieJar) Cookies(u *url.URL) []*http.Cookie {
+	jar.mu.Lock()
+	defer jar.mu.Unlock()
+
+	host := canonicalCookieHost(u.Hostname())
+	path := u.Path
+	if path == "" {
+		path = "/"
+	}
+	now := time.Now()
+
+	var result []*http.Cookie
+	for _, stored := range jar.cookies {
+		if !stored.Expires.IsZero() && !stored.Expires.After(now) {
+			continue
+		}
+		if stored.Secure && u.Scheme != "https" && u.Scheme != "wss" {
+			continue
+		}
+		if stored.HostOnly && host != stored.Domain || !stored.HostOnly && !domainMatch(host, stored.Domain) {
+			continue
+		}
+		if !pathMatch(path, stored.Path) {
+			continue
+		}
+		result = append(result, &http.Cookie{Name: stored.Name, Value: stored.Value})
+	}
+	return result
+}
+
+// List returns a copy of all cookies that have not expired yet
+func (jar *CookieJar) List() []StoredCookie {
+	jar.mu.Lock()
+	defer jar.mu.Unlock()
+
+	now := time.Now()
+	jar.cookies = slices.DeleteFunc(jar.cookies, func(c StoredCookie) bool {
+		return !c.Expires.IsZero() && !c.Expires.After(now)
+	})
+	return slices.Clone(jar.cookies)
+}
+
+// Update replaces the cookie at the given index of List
+func (jar *CookieJar) Update(index int, cookie StoredCookie) {
+	jar.mu.Lock()
+	defer jar.mu.Unlock()
+	if index >= 0 && index < len(jar.cookies) {
+		jar.cookies[index] = cookie
+		jar.changed = true
+	}
+}
+
+// Delete removes the cookie at the given index of List
+func (jar *CookieJar) Delete(index int) {
+	jar.mu.Lock()
+	defer jar.mu.Unlock()
+	if index >= 0 && index < len(jar.cookies) {
+		jar.cookies = slices.Delete(jar.cookies, index, index+1)
+		jar.changed = true
+	}
+}
+
+// Clear removes all cookies
+func (jar *CookieJar) Clear() {
+	jar.mu.Lock()
+	defer jar.mu.Unlock()
+	jar.changed = jar.changed || len(jar.cookies) > 0
+	jar.cookies = nil
+}
+
+// takeChanged reports whether the cookies changed since the last call
+func (jar *CookieJar) takeChanged() bool {
+	jar.mu.Lock()
+	defer jar.mu.Unlock()
+	changed := jar.changed
+	jar.changed = false
+	return changed
+}
+
+func (jar *CookieJar) indexOf(name, domain, path string) int {
+	return slices.IndexFunc(jar.cookies, func(c StoredCookie) bool {
+		return c.Name == name && c.Domain == domain && c.Path == path
+	})
+}
+
+func canonicalCookieHost(host string) string {
+	return strings.TrimSuffix(strings.ToLower(host), ".")
+}
+
+// domainMatch reports whether host equals domain or is a subdomain of it (RFC 6265, section 5.1.3)
+func domainMatch(host, domain string) bool {
+	if host == domain {
+		return true
+	}
+	// IP addresses never match a parent domain
+	return net.ParseIP(host) == nil && strings.HasSuffix(host, "."+domain)
+}
+
+// pathMatch implements the path-match rule of RFC 6265, section 5.1.4
+func pathMatch(requestPath, cookiePath string) bool {
+	if requestPath == cookiePath {
+		return true
+	}
+	if !strings.HasPrefix(requestPath, cookiePath) {
+		return false
+	}
+	return strings.HasSuffix(cookiePath, "/") || requestPath[len(cookiePath)] == '/'
+}
+
+// defaultCookiePath implements the default-path rule of RFC 6265, section 5.1.4
+func defaultCookiePath(requestPath string) string {
+	i := strings.LastIndex(requestPath, "/")
+	if i <= 0 {
+		return "/"
+	}
+	return requestPath[:i]
+}
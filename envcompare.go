@@ -0,0 +1,208 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+)
+
+// requestAtPath is a request of the project tree with the URL path it is sent to
+type requestAtPath struct {
+	Request *Request
+	Path    string
+}
+
+// requestsUnder returns the requests at a path of the tree and below it, all requests for
+// an empty path. Paths are built like the tree view builds them.
+func (node *RequestNode) requestsUnder(path string, pathPrefix string) []requestAtPath {
+	currentPath := pathPrefix
+	if node.Segment != "" {
+		if currentPath != "" && currentPath != "/" {
+			currentPath += "/"
+		}
+		currentPath += node.Segment
+	}
+	var requests []requestAtPath
+	if path == "" || currentPath == path || strings.HasPrefix(currentPath, path+"/") {
+		for _, request := range node.Requests {
+			requests = append(requests, requestAtPath{request, currentPath})
+		}
+	}
+	for _, child := range node.Children {
+		requests = append(requests, child.requestsUnder(path, currentPath)...)
+	}
+	return requests
+}
+
+// detachRequests copies the requests of the targets, so that a comparison running in the
+// background neither races with edits nor shares the Digest sessions of other sends
+func detachRequests(targets []requestAtPath) []requestAtPath {
+	detached := make([]requestAtPath, len(targets))
+	for i, target := range targets {
+		// Running sends set the Digest session of the request
+		digestSessionsMu.Lock()
+		request := *target.Request
+		digestSessionsMu.Unlock()
+		request.digest = nil
+		detached[i] = requestAtPath{&request, target.Path}
+	}
+	return detached
+}
+
+// EnvironmentComparison is the outcome of sending a request to two environments
+type EnvironmentComparison struct {
+	Request     *Request
+	Path        string
+	Left, Right *Environment
+	// Responses of both environments; a failed send has an error instead
+	LeftResponse, RightResponse *ResponseData
+	LeftErr, RightErr           error
+	Diff                        ResponseDiff // Difference with the ignore rules of the request applied
+}
+
+// Failed reports whether a send failed, e.g. because an environment was unreachable
+func (comparison EnvironmentComparison) Failed() bool {
+	return comparison.LeftErr != nil || comparison.RightErr != nil
+}
+
+// environmentSendOptions returns the options of sends to an environment. Cookie jars
+// are created on first use, so this is called on the UI thread before sending.
+func (p *Project) environmentSendOptions(env *Environment, settings *Settings) SendOptions {
+	timeoutInMs := int64(30000)
+	if p.Settings.TimeoutInMs > 0 {
+		timeoutInMs = p.Settings.TimeoutInMs
+	}
+	return SendOptions{
+		Settings:    settings,
+		TimeoutInMs: timeoutInMs,
+		Jar:         p.CookieJar(env.BaseURL, settings),
+		Environment: env,
+		BaseDir:     p.dir(),
+	}
+}
+
+// compareEnvironments sends the request to both environments concurrently and compares
+// the responses. Volatile fields are masked by the ignore rules of the request's snapshot.
+// The request of the target must not change meanwhile, see detachRequests.
+func compareEnvironments(ctx context.Context, target requestAtPath, left, right SendOptions) EnvironmentComparison {
+	comparison := EnvironmentComparison{Request: target.Request, Path: target.Path, Left: left.Environment, Right: right.Environment}
+	if target.Request.Kind == KindWebSocket {
+		comparison.LeftErr = errors.New("WebSocket requests cannot be compared")
+		comparison.RightErr = comparison.LeftErr
+		return comparison
+	}
+	config := target.Request.Snapshot
+	rules, err := compileSnapshotRules(config.ignoreRules())
+	if err != nil {
+		comparison.LeftErr, comparison.RightErr = err, err
+		return comparison
+	}
+	send := func(options SendOptions, response **ResponseData, sendErr *error, wg *sync.WaitGroup) {
+		defer wg.Done()
+		// Each environment gets its own copy, sent to its base URL
+		request := *target.Request
+		request.Host = options.Environment.BaseURL
+		options.Path = target.Path
+		request.sendRequest(ctx, options, func(responseData *ResponseData, err error) {
+			*response, *sendErr = responseData, err
+		})
+	}
+	var wg sync.WaitGroup
+	wg.Add(2)
+	go send(left, &comparison.LeftResponse, &comparison.LeftErr, &wg)
+	go send(right, &comparison.RightResponse, &comparison.RightErr, &wg)
+	wg.Wait()
+	if comparison.Failed() {
+		return comparison
+	}
+
+	comparison.Diff = diffResponses(
+		config.normalize(comparison.LeftResponse, rules),
+		config.normalize(comparison.RightResponse, rules),
+		left.Environment.String(), right.Environment.String(),
+	)
+	return comparison
+}
+
+// compareEnvironmentsUnder compares the requests one after another, each sent to both
+// environments at once. progress is called after each request.
+func compareEnvironmentsUnder(ctx context.Context, targets []requestAtPath, left, right SendOptions, progress func(done int)) []EnvironmentComparison {
+	comparisons := make([]EnvironmentComparison, 0, len(targets))
+	for _, target := range targets {
+		if ctx.Err() != nil {
+			break
+		}
+		comparisons = append(comparisons, compareEnvironments(ctx, target, left, right))
+		if progress != nil {
+			progress(len(comparisons))
+		}
+	}
+	return comparisons
+}
+
+// Summary describes the comparison in a line, e.g. for a list of compared requests
+func (comparison EnvironmentComparison) Summary() string {
+	name := fmt.Sprintf("[%s] %s %s", comparison.Request.methodLabel(), comparison.Path, strings.TrimSpace(comparison.Request.Name))
+	switch {
+	case comparison.Failed():
+		return "❌ " + name
+	case comparison.Diff.Equal():
+		return "✅ " + name
+	}
+	return "≠ " + name
+}
+
+// environmentReport renders the comparisons side by side: status, timing and size of both
+// environments, followed by the difference of the responses
+func environmentReport(comparisons []EnvironmentComparison, now time.Time) string {
+	const column = 48
+	row := func(label, left, right string) string {
+		return fmt.Sprintf("  %-10s %-*s %s", label, column, left, right)
+	}
+	side := func(response *ResponseData, err error) (status, timing, size string) {
+		if err != nil {
+			return "Error: " + err.Error(), "", ""
+		}
+		return response.Status, response.Duration.Round(time.Millisecond).String(), formatSize(response.Size)
+	}
+
+	identical, differing, failed := 0, 0, 0
+	for _, comparison := range comparisons {
+		switch {
+		case comparison.Failed():
+			failed++
+		case comparison.Diff.Equal():
+			identical++
+		default:
+			differing++
+		}
+	}
+	lines := []string{
+		"Environment comparison of " + now.Format("2006-01-02 15:04:05"),
+		fmt.Sprintf("%d request(s): %d identical, %d different, %d failed", len(comparisons), identical, differing, failed),
+	}
+	for _, comparison := range comparisons {
+		lines = append(lines, "", comparison.Summary())
+		lines = append(lines, row("", comparison.Left.String(), comparison.Right.String()))
+		leftStatus, leftTiming, leftSize := side(comparison.LeftResponse, comparison.LeftErr)
+		rightStatus, rightTiming, rightSize := side(comparison.RightResponse, comparison.RightErr)
+		lines = append(lines,
+			row("Status", leftStatus, rightStatus),
+			row("Time", leftTiming, rightTiming),
+			row("Size", leftSize, rightSize),
+		)
+		if comparison.Failed() || comparison.Diff.Equal() {
+			continue
+		}
+		// The labels of the diff repeat the environments shown above
+		for _, line := range strings.Split(comparison.Diff.String(), "\r\n")[2:] {
+			if line != "" {
+				lines = append(lines, "  "+line)
+			}
+		}
+	}
+	return strings.Join(lines, "\r\n")
+}
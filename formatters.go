@@ -0,0 +1,454 @@
+package main
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"mime"
+	"net/url"
+	"slices"
+	"sort"
+	"strings"
+	"unicode/utf8"
+)
+
+// formatOptions configure how a response body is displayed
+type formatOptions struct {
+	jsonIndent string // Indent of JSON, empty for a single line
+	charset    string // Charset chosen for the request, empty to detect it
+	formatter  string // Formatter chosen for the request, empty to choose by content type
+	// Schema of protobuf responses, nil to show the fields by number
+	protoSchema  *protoSchema
+	protoMessage *protoMessageType
+	protoErr     error // Problem loading the protobuf schema
+}
+
+// ResponseFormatter renders a response body for display. Text formatters get the body
+// decoded by its charset, binary formatters its bytes.
+type ResponseFormatter struct {
+	Name       string   // Stored with requests that choose the formatter
+	Label      string   // Shown in the formatter choice
+	MediaTypes []string // Media types the formatter is chosen for; "+json" matches structured syntax suffixes
+	Format     func(body string, options formatOptions) (string, bool)
+	Decode     func(data []byte, options formatOptions) (string, bool)
+}
+
+// responseFormatters is the formatter registry. A request chooses one by name,
+// otherwise the first one registered for the media type of the response is used.
+var responseFormatters = []*ResponseFormatter{
+	{Name: "links", Label: "JSON:API / HAL links", MediaTypes: []string{"application/vnd.api+json", "application/hal+json"}, Format: formatLinks},
+	{Name: "json", Label: "JSON", MediaTypes: []string{"application/json", "text/json", "+json"}, Format: func(body string, options formatOptions) (string, bool) {
+		return formatJSON([]byte(body), options.jsonIndent)
+	}},
+	{Name: "ndjson", Label: "NDJSON", MediaTypes: []string{"application/x-ndjson", "application/ndjson", "application/jsonl", "application/x-jsonlines", "application/json-seq"}, Format: formatNDJSON},
+	{Name: "html", Label: "HTML", MediaTypes: []string{"text/html", "application/xhtml+xml"}, Format: formatHTML},
+	{Name: "xml", Label: "XML", MediaTypes: []string{"application/xml", "text/xml", "+xml"}, Format: func(body string, options formatOptions) (string, bool) {
+		return formatXML(body)
+	}},
+	{Name: "yaml", Label: "YAML", MediaTypes: []string{"application/yaml", "application/x-yaml", "text/yaml", "text/x-yaml", "+yaml"}, Format: formatYAML},
+	{Name: "csv", Label: "CSV table", MediaTypes: []string{"text/csv", "application/csv", "text/tab-separated-values"}, Format: formatCSV},
+	{Name: "form", Label: "Form fields", MediaTypes: []string{"application/x-www-form-urlencoded"}, Format: formatFormBody},
+	{Name: "msgpack", Label: "MessagePack", MediaTypes: []string{"application/msgpack", "application/x-msgpack", "application/vnd.msgpack"}, Decode: decodeMessagePack},
+	{Name: "cbor", Label: "CBOR", MediaTypes: []string{"application/cbor", "+cbor"}, Decode: decodeCBOR},
+	{Name: "protobuf", Label: "Protobuf", MediaTypes: []string{"application/x-protobuf", "application/protobuf", "application/vnd.google.protobuf", "application/x-google-protobuf"}, Decode: func(data []byte, options formatOptions) (string, bool) {
+		return decodeProtobuf(data, options.protoSchema, options.protoMessage, options)
+	}},
+	{Name: "text", Label: "Plain text", MediaTypes: []string{"text/plain"}, Format: func(body string, options formatOptions) (string, bool) {
+		return windowsLineEndings(body), true
+	}},
+}
+
+// formatterNames are the choices of the formatter selection, "" chooses by content type
+var formatterNames = func() []string {
+	names := []string{""}
+	for _, formatter := range responseFormatters {
+		names = append(names, formatter.Name)
+	}
+	return names
+}()
+
+// formatterLabel returns the display name of a formatter choice
+func formatterLabel(name string) string {
+	if formatter := formatterByName(name); formatter != nil {
+		return formatter.Label
+	}
+	return "Auto format"
+}
+
+// formatterByName returns the registered formatter with the name, or nil
+func formatterByName(name string) *ResponseFormatter {
+	for _, formatter := range responseFormatters {
+		if formatter.Name == name {
+			return formatter
+		}
+	}
+	return nil
+}
+
+// formatterForMediaType returns the formatter registered for the media type,
+// matching exact types before structured syntax suffixes such as "+json"
+func formatterForMediaType(mediaType string) *ResponseFormatter {
+	for _, formatter := range responseFormatters {
+		if slices.Contains(formatter.MediaTypes, mediaType) {
+			return formatter
+		}
+	}
+	for _, formatter := range responseFormatters {
+		for _, pattern := range formatter.MediaTypes {
+			if strings.HasPrefix(pattern, "+") && strings.HasSuffix(mediaType, pattern) {
+				return formatter
+			}
+		}
+	}
+	return nil
+}
+
+// sniffFormatter guesses the formatter of a body without a known content type
+func sniffFormatter(body string) *ResponseFormatter {
+	trimmed := strings.TrimSpace(body)
+	lower := strings.ToLower(trimmed[:min(len(trimmed), 100)])
+	switch {
+	case strings.HasPrefix(trimmed, "{"), strings.HasPrefix(trimmed, "["):
+		return formatterByName("json")
+	case strings.HasPrefix(lower, "<!doctype html"), strings.HasPrefix(lower, "<html"):
+		return formatterByName("html")
+	case strings.HasPrefix(trimmed, "<"):
+		return formatterByName("xml")
+	}
+	return nil
+}
+
+// chosenFormatter returns the formatter named in options, or the one registered for the content type
+func chosenFormatter(contentType string, options formatOptions) *ResponseFormatter {
+	if formatter := formatterByName(options.formatter); formatter != nil {
+		return formatter
+	}
+	mediaType, _, _ := mime.ParseMediaType(contentType)
+	return formatterForMediaType(strings.ToLower(mediaType))
+}
+
+// formatResponse formats a text body with the chosen formatter, or by its content type.
+// Bodies no formatter accepts are shown as they are.
+func formatResponse(body string, contentType string, options formatOptions) string {
+	formatter := chosenFormatter(contentType, options)
+	if formatter == nil || formatter.Format == nil || (formatter.Name == "text" && options.formatter == "") {
+		formatter = sniffFormatter(body)
+	}
+	if formatter != nil {
+		if formatted, ok := formatter.Format(body, options); ok {
+			return formatted
+		}
+	}
+	return windowsLineEndings(body)
+}
+
+// windowsLineEndings converts any line endings to "\r\n" as expected by the edit controls
+func windowsLineEndings(text string) string {
+	return strings.ReplaceAll(strings.ReplaceAll(text, "\r\n", "\n"), "\n", "\r\n")
+}
+
+// formatNDJSON pretty-prints every line of newline delimited JSON, separated by blank lines
+func formatNDJSON(body string, options formatOptions) (string, bool) {
+	var records []string
+	for line := range strings.Lines(body) {
+		// RFC 7464 JSON text sequences prefix each record with RS
+		line = strings.TrimPrefix(strings.TrimSpace(line), "\x1e")
+		if line == "" {
+			continue
+		}
+		if formatted, ok := formatJSON([]byte(line), options.jsonIndent); ok {
+			records = append(records, formatted)
+		} else {
+			records = append(records, line)
+		}
+	}
+	if len(records) == 0 {
+		return "", false
+	}
+	return strings.Join(records, "\r\n\r\n"), true
+}
+
+// formatYAML normalizes line endings and trailing whitespace; YAML is shown as sent
+func formatYAML(body string, options formatOptions) (string, bool) {
+	lines := strings.Split(strings.ReplaceAll(body, "\r\n", "\n"), "\n")
+	for i, line := range lines {
+		lines[i] = strings.TrimRight(line, " \t")
+	}
+	return strings.Join(lines, "\r\n"), true
+}
+
+// csvColumnWidth caps the width of a column of the CSV table
+const csvColumnWidth = 60
+
+// formatCSV aligns comma, semicolon or tab separated values as a table
+func formatCSV(body string, options formatOptions) (string, bool) {
+	firstLine, _, _ := strings.Cut(body, "\n")
+	reader := csv.NewReader(strings.NewReader(body))
+	reader.FieldsPerRecord = -1
+	reader.LazyQuotes = true
+	// The separator occurring most often in the header wins
+	for _, separator := range []rune{';', '\t'} {
+		if strings.Count(firstLine, string(separator)) > strings.Count(firstLine, string(reader.Comma)) {
+			reader.Comma = separator
+		}
+	}
+	records, err := reader.ReadAll()
+	if err != nil || len(records) == 0 {
+		return "", false
+	}
+
+	var widths []int
+	for _, record := range records {
+		for i, field := range record {
+			if i == len(widths) {
+				widths = append(widths, 0)
+			}
+			widths[i] = min(max(widths[i], utf8.RuneCountInString(field)), csvColumnWidth)
+		}
+	}
+	var builder strings.Builder
+	writeRow := func(fields []string) {
+		for i, field := range fields {
+			if i > 0 {
+				builder.WriteString(" | ")
+			}
+			field = strings.NewReplacer("\r\n", "↵", "\n", "↵").Replace(field)
+			if utf8.RuneCountInString(field) > csvColumnWidth {
+				field = string([]rune(field)[:csvColumnWidth-1]) + "…"
+			}
+			builder.WriteString(field)
+			if i < len(fields)-1 {
+				builder.WriteString(strings.Repeat(" ", widths[i]-utf8.RuneCountInString(field)))
+			}
+		}
+		builder.WriteString("\r\n")
+	}
+	writeRow(records[0])
+	var rule []string
+	for _, width := range widths {
+		rule = append(rule, strings.Repeat("-", width))
+	}
+	builder.WriteString(strings.Join(rule, "-+-") + "\r\n")
+	for _, record := range records[1:] {
+		writeRow(record)
+	}
+	return builder.String(), true
+}
+
+// formatFormBody lists the fields of a URL encoded form, one "name: value" per line
+func formatFormBody(body string, options formatOptions) (string, bool) {
+	var fields Params
+	for pair := range strings.SplitSeq(strings.TrimSpace(body), "&") {
+		if pair == "" {
+			continue
+		}
+		name, value, _ := strings.Cut(pair, "=")
+		name, err := url.QueryUnescape(name)
+		if err != nil {
+			return "", false
+		}
+		if value, err = url.QueryUnescape(value); err != nil {
+			return "", false
+		}
+		fields = append(fields, Param{Name: name, Value: value})
+	}
+	return fields.Format(), true
+}
+
+// formatLinks lists the links of a JSON:API or HAL document above the pretty-printed document
+func formatLinks(body string, options formatOptions) (string, bool) {
+	formatted, ok := formatJSON([]byte(body), options.jsonIndent)
+	if !ok {
+		return "", false
+	}
+	var document any
+	if err := json.Unmarshal([]byte(body), &document); err != nil {
+		return "", false
+	}
+	var links []string
+	collectLinks(document, "", &links)
+	if len(links) == 0 {
+		return formatted, true
+	}
+	return "Links:\r\n" + strings.Join(links, "\r\n") + "\r\n\r\n" + formatted, true
+}
+
+// collectLinks appends "path rel: href" for the entries of every "links" (JSON:API)
+// and "_links" (HAL) object in the document
+func collectLinks(value any, path string, links *[]string) {
+	switch value := value.(type) {
+	case map[string]any:
+		keys := make([]string, 0, len(value))
+		for key := range value {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		for _, key := range keys {
+			child := value[key]
+			if object, ok := child.(map[string]any); ok && (key == "links" || key == "_links") {
+				rels := make([]string, 0, len(object))
+				for rel := range object {
+					rels = append(rels, rel)
+				}
+				sort.Strings(rels)
+				for _, rel := range rels {
+					for _, href := range linkHrefs(object[rel]) {
+						*links = append(*links, fmt.Sprintf("  %s%s: %s", path, rel, href))
+					}
+				}
+			}
+			collectLinks(child, path+key+".", links)
+		}
+	case []any:
+		for i, child := range value {
+			collectLinks(child, fmt.Sprintf("%s[%d].", strings.TrimSuffix(path, "."), i), links)
+		}
+	}
+}
+
+// linkHrefs returns the targets of a link, given as URL string, link object or list of link objects
+func linkHrefs(link any) []string {
+	switch link := link.(type) {
+	case string:
+		return []string{link}
+	case map[string]any:
+		if href, ok := link["href"].(string); ok {
+			return []string{href}
+		}
+	case []any:
+		var hrefs []string
+		for _, entry := range link {
+			hrefs = append(hrefs, linkHrefs(entry)...)
+		}
+		return hrefs
+	}
+	return nil
+}
+
+// htmlVoidElements have no content and no end tag
+var htmlVoidElements = map[string]bool{
+	"area": true, "base": true, "br": true, "col": true, "embed": true, "hr": true, "img": true,
+	"input": true, "link": true, "meta": true, "param": true, "source": true, "track": true, "wbr": true,
+}
+
+// htmlRawElements keep their content as sent
+var htmlRawElements = map[string]bool{"script": true, "style": true, "pre": true, "textarea": true}
+
+// htmlSelfClosing are elements whose end tag may be omitted before a sibling of the same name
+var htmlSelfClosing = map[string]bool{"p": true, "li": true, "dt": true, "dd": true, "tr": true, "td": true, "th": true, "option": true}
+
+// formatHTML puts every tag and text run on its own line, indented by nesting depth
+func formatHTML(body string, options formatOptions) (string, bool) {
+	if !strings.Contains(body, "<") {
+		return "", false
+	}
+	var builder strings.Builder
+	var open []string // Names of the enclosing elements
+	line := func(text string) {
+		if builder.Len() > 0 {
+			builder.WriteString("\r\n")
+		}
+		builder.WriteString(strings.Repeat("  ", len(open)))
+		builder.WriteString(text)
+	}
+	text := func(text string) {
+		if text = strings.Join(strings.Fields(text), " "); text != "" {
+			line(text)
+		}
+	}
+
+	rest := body
+	for rest != "" {
+		start := strings.IndexByte(rest, '<')
+		if start < 0 {
+			text(rest)
+			break
+		}
+		text(rest[:start])
+		rest = rest[start:]
+		if strings.HasPrefix(rest, "<!--") {
+			end := strings.Index(rest, "-->")
+			if end < 0 {
+				end = len(rest) - 3
+			}
+			line(windowsLineEndings(rest[:end+3]))
+			rest = rest[end+3:]
+			continue
+		}
+		end := htmlTagEnd(rest)
+		tag := rest[:end]
+		rest = rest[end:]
+		name := htmlTagName(tag)
+		switch {
+		case strings.HasPrefix(tag, "</"):
+			// Close up to the matching element, ignoring stray end tags
+			if i := slices.Index(open, name); i >= 0 {
+				for len(open) > i {
+					open = open[:len(open)-1]
+				}
+			}
+			line(tag)
+		case strings.HasPrefix(tag, "<!"), strings.HasPrefix(tag, "<?"), htmlVoidElements[name], strings.HasSuffix(tag, "/>"):
+			line(tag)
+		case htmlRawElements[name]:
+			closing := htmlEndTagIndex(rest, name)
+			content := rest[:closing]
+			rest = rest[closing:]
+			closeEnd := len(rest)
+			if rest != "" {
+				closeEnd = htmlTagEnd(rest)
+			}
+			line(tag + windowsLineEndings(content) + rest[:closeEnd])
+			rest = rest[closeEnd:]
+		default:
+			if len(open) > 0 && open[len(open)-1] == name && htmlSelfClosing[name] {
+				open = open[:len(open)-1]
+			}
+			line(tag)
+			open = append(open, name)
+		}
+	}
+	return builder.String(), true
+}
+
+// htmlTagEnd returns the index after the '>' closing the tag at the start of s, skipping quoted attribute values
+func htmlTagEnd(s string) int {
+	var quote byte
+	for i := 1; i < len(s); i++ {
+		switch {
+		case quote != 0:
+			if s[i] == quote {
+				quote = 0
+			}
+		case s[i] == '"' || s[i] == '\'':
+			quote = s[i]
+		case s[i] == '>':
+			return i + 1
+		}
+	}
+	return len(s)
+}
+
+// htmlEndTagIndex returns the index of the end tag of the element in s, len(s) if there is none.
+// Names are compared without lowering s, which would move the offsets of invalid UTF-8.
+func htmlEndTagIndex(s, name string) int {
+	for i := 0; ; i += 2 {
+		next := strings.Index(s[i:], "</")
+		if next < 0 {
+			return len(s)
+		}
+		i += next
+		if end := i + 2 + len(name); end <= len(s) && strings.EqualFold(s[i+2:end], name) {
+			return i
+		}
+	}
+}
+
+// htmlTagName returns the lower case element name of a start or end tag
+func htmlTagName(tag string) string {
+	name := strings.TrimPrefix(tag[1:], "/")
+	if end := strings.IndexAny(name, " \t\r\n/>"); end >= 0 {
+		name = name[:end]
+	}
+	return strings.ToLower(name)
+}
@@ -0,0 +1,28 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestFormatHTMLRawElements(t *testing.T) {
+	tests := []struct {
+		name string
+		body string
+		want string
+	}{
+		{"script", "<div><script>if (a < b) {}</script></div>", "<div>\r\n  <script>if (a < b) {}</script>\r\n</div>"},
+		{"upper case end tag", "<STYLE>p {}</STYLE><p>x</p>", "<STYLE>p {}</STYLE>\r\n<p>\r\n  x\r\n</p>"},
+		{"unclosed", "<script>var s = 1", "<script>var s = 1"},
+		// Latin-1 without a declared charset, invalid UTF-8 must keep its byte offsets
+		{"invalid UTF-8", "<script>" + strings.Repeat("\xe9", 20) + "</script><p>x</p>", "<script>" + strings.Repeat("\xe9", 20) + "</script>\r\n<p>\r\n  x\r\n</p>"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got, ok := formatHTML(test.body, formatOptions{})
+			if !ok || got != test.want {
+				t.Errorf("formatHTML(%q) = %q, %v, want %q", test.body, got, ok, test.want)
+			}
+		})
+	}
+}
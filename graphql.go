@@ -0,0 +1,209 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// GraphQLBody holds the parts of a GraphQL request, sent as JSON envelope
+type GraphQLBody struct {
+	Query         string `json:"query"`
+	Variables     string `json:"variables,omitempty"`     // JSON object with the variables of the query
+	OperationName string `json:"operationName,omitempty"` // Operation to execute if the query defines several
+}
+
+// envelope builds the JSON document POSTed to the GraphQL endpoint
+func (body *GraphQLBody) envelope() ([]byte, error) {
+	envelope := struct {
+		Query         string          `json:"query"`
+		Variables     json.RawMessage `json:"variables,omitempty"`
+		OperationName string          `json:"operationName,omitempty"`
+	}{
+		Query:         body.Query,
+		OperationName: strings.TrimSpace(body.OperationName),
+	}
+	if variables := strings.TrimSpace(body.Variables); variables != "" {
+		var object map[string]any
+		if err := json.Unmarshal([]byte(variables), &object); err != nil {
+			return nil, fmt.Errorf("invalid GraphQL variables: %v", err)
+		}
+		envelope.Variables = json.RawMessage(variables)
+	}
+	return json.Marshal(envelope)
+}
+
+// graphQLError is an entry of the "errors" list of a GraphQL response
+type graphQLError struct {
+	Message   string `json:"message"`
+	Path      []any  `json:"path"`
+	Locations []struct {
+		Line   int `json:"line"`
+		Column int `json:"column"`
+	} `json:"locations"`
+}
+
+// graphQLFailures returns the errors reported in a GraphQL response body, one line each
+func graphQLFailures(body []byte) []string {
+	var response struct {
+		Errors []graphQLError `json:"errors"`
+	}
+	if err := json.Unmarshal(body, &response); err != nil {
+		return nil
+	}
+	var failures []string
+	for _, e := range response.Errors {
+		failure := e.Message
+		if len(e.Path) > 0 {
+			var path []string
+			for _, segment := range e.Path {
+				path = append(path, fmt.Sprint(segment))
+			}
+			failure += " (path: " + strings.Join(path, ".") + ")"
+		}
+		if len(e.Locations) > 0 {
+			failure += fmt.Sprintf(" at line %d, column %d", e.Locations[0].Line, e.Locations[0].Column)
+		}
+		failures = append(failures, failure)
+	}
+	return failures
+}
+
+// introspectionQuery fetches the full schema, as used by common GraphQL tools
+const introspectionQuery = `query IntrospectionQuery {
+  __schema {
+    queryType { name }
+    mutationType { name }
+    subscriptionType { name }
+    types { ...FullType }
+    directives {
+      name
+      description
+      locations
+      args { ...InputValue }
+    }
+  }
+}
+
+fragment FullType on __Type {
+  kind
+  name
+  description
+  fields(includeDeprecated: true) {
+    name
+    description
+    args { ...InputValue }
+    type { ...TypeRef }
+    isDeprecated
+    deprecationReason
+  }
+  inputFields { ...InputValue }
+  interfaces { ...TypeRef }
+  enumValues(includeDeprecated: true) {
+    name
+    description
+    isDeprecated
+    deprecationReason
+  }
+  possibleTypes { ...TypeRef }
+}
+
+fragment InputValue on __InputValue {
+  name
+  description
+  type { ...TypeRef }
+  defaultValue
+}
+
+fragment TypeRef on __Type {
+  kind
+  name
+  ofType {
+    kind
+    name
+    ofType {
+      kind
+      name
+      ofType {
+        kind
+        name
+        ofType { kind name ofType { kind name ofType { kind name } } }
+      }
+    }
+  }
+}`
+
+// introspectGraphQL sends the introspection query to the endpoint of the request,
+// using its host, headers and authentication, and returns the __schema object
+func (request *Request) introspectGraphQL(ctx context.Context, options SendOptions, callback func(schema json.RawMessage, err error)) {
+	introspection := *request
+	introspection.Method = "POST"
+	introspection.BodyMode = BodyGraphQL
+	introspection.GraphQL = &GraphQLBody{Query: introspectionQuery}
+	introspection.sendRequest(ctx, options, func(responseData *ResponseData, err error) {
+		if err != nil {
+			callback(nil, err)
+			return
+		}
+		if len(responseData.Failures) > 0 {
+			callback(nil, fmt.Errorf("introspection failed: %s", strings.Join(responseData.Failures, "; ")))
+			return
+		}
+		if responseData.StatusCode < 200 || responseData.StatusCode > 299 {
+			callback(nil, fmt.Errorf("introspection failed: %s", responseData.Status))
+			return
+		}
+		var response struct {
+			Data struct {
+				Schema json.RawMessage `json:"__schema"`
+			} `json:"data"`
+		}
+		if err := json.Unmarshal(responseData.content(), &response); err != nil {
+			callback(nil, fmt.Errorf("invalid introspection response: %v", err))
+			return
+		}
+		if len(response.Data.Schema) == 0 || string(response.Data.Schema) == "null" {
+			callback(nil, fmt.Errorf("response contains no schema"))
+			return
+		}
+		callback(response.Data.Schema, nil)
+	})
+}
+
+// GraphQLSchema returns the stored introspection result of the endpoint, or nil
+func (p *Project) GraphQLSchema(endpoint string) json.RawMessage {
+	return p.GraphQLSchemas[endpoint]
+}
+
+// SetGraphQLSchema stores the introspection result of the endpoint with the project
+func (p *Project) SetGraphQLSchema(endpoint string, schema json.RawMessage) {
+	if p.GraphQLSchemas == nil {
+		p.GraphQLSchemas = make(map[string]json.RawMessage)
+	}
+	p.GraphQLSchemas[endpoint] = schema
+}
+
+// graphQLSchemaSummary describes a schema in one line, e.g. "42 types, query Query, mutation Mutation"
+func graphQLSchemaSummary(schema json.RawMessage) string {
+	var parsed struct {
+		QueryType        *struct{ Name string } `json:"queryType"`
+		MutationType     *struct{ Name string } `json:"mutationType"`
+		SubscriptionType *struct{ Name string } `json:"subscriptionType"`
+		Types            []json.RawMessage      `json:"types"`
+	}
+	if err := json.Unmarshal(schema, &parsed); err != nil {
+		return "invalid schema"
+	}
+	parts := []string{fmt.Sprintf("%d types", len(parsed.Types))}
+	if parsed.QueryType != nil {
+		parts = append(parts, "query "+parsed.QueryType.Name)
+	}
+	if parsed.MutationType != nil {
+		parts = append(parts, "mutation "+parsed.MutationType.Name)
+	}
+	if parsed.SubscriptionType != nil {
+		parts = append(parts, "subscription "+parsed.SubscriptionType.Name)
+	}
+	return strings.Join(parts, ", ")
+}
@@ -0,0 +1,372 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+// defaultHistoryLimit is the number of responses kept per request unless configured otherwise
+const defaultHistoryLimit = 50
+
+// maxRecentSends is the number of sends listed as recent sends of a project
+const maxRecentSends = 25
+
+// maxHistoryLine limits a line read from a history file; files of older versions stored bodies up to the memory cap
+const maxHistoryLine = 256 << 20
+
+// maxHistoryBody limits the body stored with a response, of larger bodies the beginning is kept
+const maxHistoryBody = 1 << 20
+
+// historyMu serializes the access to history files, which are written in the background
+var historyMu sync.Mutex
+
+// historyAppends counts the lines appended to each history file in this session
+var historyAppends = map[string]int{}
+
+// HistoryEntry is a response stored in the history of a request.
+// The history of a request is a file of JSON lines, oldest first, in a
+// directory next to the project file named after it, e.g. "api.rtp.history".
+type HistoryEntry struct {
+	RequestID string       `json:"requestId"`
+	Name      string       `json:"name"`   // Name of the request when it was sent
+	Method    string       `json:"method"` // Method label, e.g. "GET" or "WS"
+	Path      string       `json:"path"`   // URL path of the request
+	Response  ResponseData `json:"response"`
+}
+
+// newRequestID returns a random identifier for a request
+func newRequestID() string {
+	var id [8]byte
+	rand.Read(id[:])
+	return hex.EncodeToString(id[:])
+}
+
+// assignRequestIDs gives an ID to requests that have none. The ID is derived from the
+// method, path and name the project file holds, so that requests of a file written before
+// IDs existed keep their history and snapshots until the project is saved with them, even
+// if other requests are added, removed or reordered meanwhile. Requests that agree in all
+// three are told apart by their order; reordering just those swaps their histories.
+func (p *Project) assignRequestIDs() {
+	p.Tree.assignRequestIDs("", map[string]int{})
+}
+
+func (node *RequestNode) assignRequestIDs(pathPrefix string, seen map[string]int) {
+	currentPath := pathPrefix
+	if node.Segment != "" {
+		if currentPath != "" && currentPath != "/" {
+			currentPath += "/"
+		}
+		currentPath += node.Segment
+	}
+	for _, request := range node.Requests {
+		if request.ID == "" {
+			key := request.methodLabel() + "\x00" + currentPath + "\x00" + request.Name
+			sum := sha256.Sum256(fmt.Appendf(nil, "%s\x00%d", key, seen[key]))
+			seen[key]++
+			request.ID = hex.EncodeToString(sum[:8])
+		}
+	}
+	for _, child := range node.Children {
+		child.assignRequestIDs(currentPath, seen)
+	}
+}
+
+// historyDir returns the directory of the response history, empty if the project was not saved yet
+func (p *Project) historyDir() string {
+	if p.filePath == "" {
+		return ""
+	}
+	return p.filePath + ".history"
+}
+
+// historyFile returns the history file of a request
+func (p *Project) historyFile(requestID string) string {
+	return filepath.Join(p.historyDir(), requestID+".jsonl")
+}
+
+// historyLimit returns the number of responses kept per request
+func (s ProjectSettings) historyLimit() int {
+	if s.HistoryLimit > 0 {
+		return s.HistoryLimit
+	}
+	return defaultHistoryLimit
+}
+
+// historyCutoff returns the time before which responses are dropped, zero to keep them regardless of age
+func (s ProjectSettings) historyCutoff(now time.Time) time.Time {
+	if s.HistoryDays <= 0 {
+		return time.Time{}
+	}
+	return now.AddDate(0, 0, -s.HistoryDays)
+}
+
+// historyLine is a line of a history file with the time of its response
+type historyLine struct {
+	data      []byte
+	timestamp time.Time
+}
+
+// readHistoryLines reads the lines of a history file; a missing file has no lines
+func readHistoryLines(path string) ([]historyLine, error) {
+	file, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	var lines []historyLine
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 64<<10), maxHistoryLine)
+	for scanner.Scan() {
+		data := bytes.TrimSpace(scanner.Bytes())
+		if len(data) == 0 {
+			continue
+		}
+		var stored struct {
+			Response struct{ Timestamp time.Time }
+		}
+		// Lines that cannot be read, e.g. after a crash while writing, are dropped
+		if json.Unmarshal(data, &stored) != nil {
+			continue
+		}
+		lines = append(lines, historyLine{data: slices.Clone(data), timestamp: stored.Response.Timestamp})
+	}
+	return lines, scanner.Err()
+}
+
+// retainHistory returns the lines kept by the retention of the project settings
+func (s ProjectSettings) retainHistory(lines []historyLine, now time.Time) []historyLine {
+	cutoff := s.historyCutoff(now)
+	// Lines are in the order of sending, which differs from the time order if the clock was changed
+	slices.SortStableFunc(lines, func(a, b historyLine) int {
+		return a.timestamp.Compare(b.timestamp)
+	})
+	lines = slices.DeleteFunc(lines, func(line historyLine) bool {
+		return line.timestamp.Before(cutoff)
+	})
+	if limit := s.historyLimit(); len(lines) > limit {
+		lines = lines[len(lines)-limit:]
+	}
+	return lines
+}
+
+// recordHistory stores a response in the history of the request. The entry is built
+// on the calling thread, the file is written in the background and done is called
+// from there with the outcome. Unsaved projects keep no history.
+func (p *Project) recordHistory(request *Request, path string, response ResponseData, done func(error)) {
+	if p.historyDir() == "" {
+		return
+	}
+	if request.ID == "" {
+		request.ID = newRequestID()
+	}
+	// The temporary file of a truncated body is removed with the response, only its beginning is kept
+	response.BodyFile = ""
+	if len(response.Raw) > maxHistoryBody {
+		// Compressed bodies cannot be cut, the beginning of the decompressed body is kept
+		body := response.content()
+		response.Raw = body[:min(len(body), maxHistoryBody)]
+		response.Decompressed = true
+		response.Truncated = true
+	}
+	data, err := json.Marshal(HistoryEntry{
+		RequestID: request.ID,
+		Name:      request.Name,
+		Method:    request.methodLabel(),
+		Path:      path,
+		Response:  response,
+	})
+	if err != nil {
+		done(fmt.Errorf("error storing response: %v", err))
+		return
+	}
+	dir, file, settings := p.historyDir(), p.historyFile(request.ID), p.Settings
+	go func() {
+		done(appendHistory(dir, file, data, settings))
+	}()
+}
+
+// appendHistory appends a line to a history file. The file is pruned to the retention
+// on the first append of a session and then every time the limit was appended again,
+// so it is not read on every send.
+func appendHistory(dir, file string, data []byte, settings ProjectSettings) error {
+	historyMu.Lock()
+	defer historyMu.Unlock()
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return fmt.Errorf("error creating history directory: %v", err)
+	}
+	f, err := os.OpenFile(file, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return fmt.Errorf("error writing history: %v", err)
+	}
+	_, err = f.Write(append(data, '\n'))
+	f.Close()
+	if err != nil {
+		return fmt.Errorf("error writing history: %v", err)
+	}
+
+	appends := historyAppends[file]
+	historyAppends[file] = appends + 1
+	if appends%settings.historyLimit() != 0 {
+		return nil
+	}
+	lines, err := readHistoryLines(file)
+	if err != nil {
+		return fmt.Errorf("error reading history: %v", err)
+	}
+	if kept := settings.retainHistory(lines, time.Now()); len(kept) < len(lines) {
+		return writeHistoryLines(file, kept)
+	}
+	return nil
+}
+
+// writeHistoryLines replaces a history file; the new content is written to a
+// temporary file first so that a failed write keeps the old history
+func writeHistoryLines(path string, lines []historyLine) error {
+	if len(lines) == 0 {
+		if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+			return fmt.Errorf("error removing history: %v", err)
+		}
+		return nil
+	}
+	var buffer bytes.Buffer
+	for _, line := range lines {
+		buffer.Write(line.data)
+		buffer.WriteByte('\n')
+	}
+	temp := path + ".tmp"
+	if err := os.WriteFile(temp, buffer.Bytes(), 0644); err != nil {
+		return fmt.Errorf("error writing history: %v", err)
+	}
+	if err := os.Rename(temp, path); err != nil {
+		os.Remove(temp)
+		return fmt.Errorf("error writing history: %v", err)
+	}
+	return nil
+}
+
+// loadHistory returns the stored responses of a request within the retention, newest first
+func (p *Project) loadHistory(request *Request, settings *Settings) ([]ResponseData, error) {
+	if p.historyDir() == "" || request.ID == "" {
+		return nil, nil
+	}
+	historyMu.Lock()
+	lines, err := readHistoryLines(p.historyFile(request.ID))
+	historyMu.Unlock()
+	if err != nil {
+		return nil, fmt.Errorf("error reading history: %v", err)
+	}
+	lines = p.Settings.retainHistory(lines, time.Now())
+	responses := make([]ResponseData, 0, len(lines))
+	for _, line := range slices.Backward(lines) {
+		var entry HistoryEntry
+		if err := json.Unmarshal(line.data, &entry); err != nil {
+			continue
+		}
+		response := entry.Response
+		// Shown with the current display choices of the request
+		response.format = request.responseFormat(response.ContentType, settings, p.dir())
+		responses = append(responses, response)
+	}
+	return responses, nil
+}
+
+// clearHistory removes the stored responses of a request
+func (p *Project) clearHistory(request *Request) error {
+	if p.historyDir() == "" || request.ID == "" {
+		return nil
+	}
+	historyMu.Lock()
+	defer historyMu.Unlock()
+	return writeHistoryLines(p.historyFile(request.ID), nil)
+}
+
+// recentSends returns the latest sends of all requests of the project, newest first.
+// The responses of the entries carry no body.
+func (p *Project) recentSends(limit int) ([]HistoryEntry, error) {
+	if p.historyDir() == "" {
+		return nil, nil
+	}
+	files, err := filepath.Glob(filepath.Join(p.historyDir(), "*.jsonl"))
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	var entries []HistoryEntry
+	for _, file := range files {
+		historyMu.Lock()
+		lines, err := readHistoryLines(file)
+		historyMu.Unlock()
+		if err != nil {
+			return nil, fmt.Errorf("error reading history: %v", err)
+		}
+		lines = p.Settings.retainHistory(lines, now)
+		// Only the latest sends of each request can be among the recent ones
+		for _, line := range lines[max(len(lines)-limit, 0):] {
+			// The body is not decoded
+			var stored struct {
+				HistoryEntry
+				Response struct {
+					StatusCode int
+					Status     string
+					Timestamp  time.Time
+				} `json:"response"`
+			}
+			if err := json.Unmarshal(line.data, &stored); err != nil {
+				continue
+			}
+			entry := stored.HistoryEntry
+			entry.Response = ResponseData{StatusCode: stored.Response.StatusCode, Status: stored.Response.Status, Timestamp: stored.Response.Timestamp}
+			entries = append(entries, entry)
+		}
+	}
+	slices.SortStableFunc(entries, func(a, b HistoryEntry) int {
+		return b.Response.Timestamp.Compare(a.Response.Timestamp)
+	})
+	return entries[:min(len(entries), limit)], nil
+}
+
+// String describes the send for the list of recent sends
+func (entry HistoryEntry) String() string {
+	name := entry.Name
+	if name == "" {
+		name = entry.Path
+	}
+	return fmt.Sprintf("%s  [%s] %s  %s", entry.Response.Timestamp.Format("2006-01-02 15:04:05"), entry.Method, strings.TrimSpace(name), entry.Response.Status)
+}
+
+// findRequest returns the request with the ID and its path as shown in the project tree
+func (node *RequestNode) findRequest(id string, pathPrefix string) (*Request, string) {
+	currentPath := pathPrefix
+	if node.Segment != "" {
+		if currentPath != "" && currentPath != "/" {
+			currentPath += "/"
+		}
+		currentPath += node.Segment
+	}
+	for _, request := range node.Requests {
+		if request.ID == id {
+			return request, currentPath
+		}
+	}
+	for _, child := range node.Children {
+		if request, path := child.findRequest(id, currentPath); request != nil {
+			return request, path
+		}
+	}
+	return nil, ""
+}
@@ -0,0 +1,49 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestAssignRequestIDs(t *testing.T) {
+	load := func(tree string) map[string]string {
+		t.Helper()
+		path := filepath.Join(t.TempDir(), "api.rtp")
+		if err := os.WriteFile(path, []byte(`{"tree":`+tree+`}`), 0644); err != nil {
+			t.Fatal(err)
+		}
+		project, err := LoadProject(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		ids := map[string]string{}
+		for _, request := range project.Tree.GetAllRequests() {
+			if request.ID == "" || ids[request.Name] != "" {
+				t.Fatalf("request %q has ID %q", request.Name, request.ID)
+			}
+			ids[request.Name] = request.ID
+		}
+		return ids
+	}
+
+	before := load(`{"segment":"/","requests":[{"name":"list","method":"GET"}],"children":[{"segment":"users","requests":[{"name":"get","method":"GET"},{"name":"add","method":"POST"}]}]}`)
+	// A request added before the others and a reordering keep the IDs of the other requests
+	after := load(`{"segment":"/","requests":[{"name":"new","method":"GET"},{"name":"list","method":"GET"}],"children":[{"segment":"users","requests":[{"name":"add","method":"POST"},{"name":"get","method":"GET"}]}]}`)
+	for name, id := range before {
+		if after[name] != id {
+			t.Errorf("ID of %q changed from %s to %s", name, id, after[name])
+		}
+	}
+
+	// Requests with the same method, path and name get different IDs
+	path := filepath.Join(t.TempDir(), "same.rtp")
+	os.WriteFile(path, []byte(`{"tree":{"segment":"/","requests":[{"name":"x","method":"GET"},{"name":"x","method":"GET"}]}}`), 0644)
+	project, err := LoadProject(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if requests := project.Tree.GetAllRequests(); requests[0].ID == requests[1].ID {
+		t.Errorf("duplicate requests share the ID %s", requests[0].ID)
+	}
+}
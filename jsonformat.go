@@ -0,0 +1,115 @@
+package main
+
+import (
+	"encoding/json"
+	"strconv"
+	"strings"
+)
+
+// defaultJSONIndent indents formatted JSON unless configured otherwise
+const defaultJSONIndent = "  "
+
+// jsonIndent returns the indent of one level of formatted JSON.
+// The setting holds a number of spaces or "tab".
+func (settings *Settings) jsonIndent() string {
+	if settings == nil {
+		return defaultJSONIndent
+	}
+	value := strings.TrimSpace(settings.JSONIndent)
+	if strings.EqualFold(value, "tab") {
+		return "\t"
+	}
+	if spaces, err := strconv.Atoi(value); err == nil && spaces >= 0 && spaces <= 16 {
+		return strings.Repeat(" ", spaces)
+	}
+	return defaultJSONIndent
+}
+
+// formatJSON pretty-prints JSON token by token without building a tree.
+// Keys keep their order and strings and numbers are copied byte for byte,
+// so large integers and escapes show exactly what the server sent.
+// An empty indent puts the whole document compactly on one line.
+func formatJSON(input []byte, indent string) (string, bool) {
+	if !json.Valid(input) {
+		return "", false
+	}
+
+	var out strings.Builder
+	out.Grow(len(input) + len(input)/4)
+	depth := 0
+	newline := func() {
+		if indent == "" {
+			return
+		}
+		out.WriteString("\r\n")
+		for range depth {
+			out.WriteString(indent)
+		}
+	}
+	for i := 0; i < len(input); i++ {
+		switch c := input[i]; c {
+		case ' ', '\t', '\r', '\n':
+			// Insignificant whitespace is replaced by the layout
+		case '"':
+			end := jsonStringEnd(input, i)
+			out.Write(input[i:end])
+			i = end - 1
+		case '{', '[':
+			// Empty objects and arrays stay on one line
+			next := jsonSkipSpace(input, i+1)
+			if next < len(input) && (input[next] == '}' || input[next] == ']') {
+				out.WriteByte(c)
+				out.WriteByte(input[next])
+				i = next
+				continue
+			}
+			out.WriteByte(c)
+			depth++
+			newline()
+		case '}', ']':
+			depth--
+			newline()
+			out.WriteByte(c)
+		case ',':
+			out.WriteByte(',')
+			newline()
+		case ':':
+			// Single-line output is compact like the rest of its delimiters
+			if indent == "" {
+				out.WriteByte(':')
+			} else {
+				out.WriteString(": ")
+			}
+		default:
+			// Numbers and literals run until the next delimiter
+			end := i
+			for end < len(input) && !strings.ContainsRune(" \t\r\n,:]}", rune(input[end])) {
+				end++
+			}
+			out.Write(input[i:end])
+			i = end - 1
+		}
+	}
+	return out.String(), true
+}
+
+// jsonStringEnd returns the index after the closing quote of the string starting at start
+func jsonStringEnd(input []byte, start int) int {
+	for i := start + 1; i < len(input); i++ {
+		switch input[i] {
+		case '\\':
+			i++
+		case '"':
+			return i + 1
+		}
+	}
+	return len(input)
+}
+
+// jsonSkipSpace returns the index of the first non-whitespace byte from start on
+func jsonSkipSpace(input []byte, start int) int {
+	for start < len(input) && strings.IndexByte(" \t\r\n", input[start]) >= 0 {
+		start++
+	}
+	return start
+}
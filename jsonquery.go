@@ -0,0 +1,1887 @@
+package main
+
+import (
+	"bytes"
+	"cmp"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"regexp"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// jsonObject is a JSON object that keeps its keys in document order
+type jsonObject struct {
+	keys   []string
+	values map[string]any
+}
+
+func newJSONObject() *jsonObject {
+	return &jsonObject{values: map[string]any{}}
+}
+
+// set sets the value of a key, new keys are appended
+func (object *jsonObject) set(key string, value any) {
+	if _, ok := object.values[key]; !ok {
+		object.keys = append(object.keys, key)
+	}
+	object.values[key] = value
+}
+
+func (object *jsonObject) clone() *jsonObject {
+	copied := newJSONObject()
+	for _, key := range object.keys {
+		copied.set(key, object.values[key])
+	}
+	return copied
+}
+
+// parseJSONValues reads the JSON values of a document or of a sequence like NDJSON.
+// Numbers are kept as json.Number so that they are written as they were received.
+func parseJSONValues(data []byte) ([]any, error) {
+	// RFC 7464 JSON text sequences separate records with RS
+	data = bytes.ReplaceAll(data, []byte{0x1e}, []byte{' '})
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	var values []any
+	for {
+		value, err := readJSONValue(decoder)
+		if err == io.EOF {
+			if len(values) == 0 {
+				return nil, errors.New("the body is not JSON")
+			}
+			return values, nil
+		}
+		if err != nil {
+			return nil, fmt.Errorf("the body is not JSON: %v", err)
+		}
+		values = append(values, value)
+	}
+}
+
+// readJSONValue reads the next value of the decoder
+func readJSONValue(decoder *json.Decoder) (any, error) {
+	token, err := decoder.Token()
+	if err != nil {
+		return nil, err
+	}
+	delim, ok := token.(json.Delim)
+	if !ok {
+		return token, nil
+	}
+	// Running out of data inside an array or object is an error
+	nested := func(err error) error {
+		if err == io.EOF {
+			return io.ErrUnexpectedEOF
+		}
+		return err
+	}
+	switch delim {
+	case '[':
+		array := []any{}
+		for decoder.More() {
+			value, err := readJSONValue(decoder)
+			if err != nil {
+				return nil, nested(err)
+			}
+			array = append(array, value)
+		}
+		_, err := decoder.Token()
+		return array, nested(err)
+	case '{':
+		object := newJSONObject()
+		for decoder.More() {
+			key, err := decoder.Token()
+			if err != nil {
+				return nil, nested(err)
+			}
+			value, err := readJSONValue(decoder)
+			if err != nil {
+				return nil, nested(err)
+			}
+			object.set(key.(string), value)
+		}
+		_, err := decoder.Token()
+		return object, nested(err)
+	}
+	return nil, fmt.Errorf("unexpected %v", delim)
+}
+
+// appendJSONValue appends a value as compact JSON
+func appendJSONValue(dst []byte, value any) []byte {
+	switch value := value.(type) {
+	case nil:
+		return append(dst, "null"...)
+	case bool:
+		return strconv.AppendBool(dst, value)
+	case json.Number:
+		return append(dst, value...)
+	case float64:
+		return appendJSONFloat(dst, value, 64)
+	case string:
+		return appendJSONString(dst, value)
+	case []any:
+		dst = append(dst, '[')
+		for i, element := range value {
+			if i > 0 {
+				dst = append(dst, ',')
+			}
+			dst = appendJSONValue(dst, element)
+		}
+		return append(dst, ']')
+	case *jsonObject:
+		dst = append(dst, '{')
+		for i, key := range value.keys {
+			if i > 0 {
+				dst = append(dst, ',')
+			}
+			dst = appendJSONString(dst, key)
+			dst = append(dst, ':')
+			dst = appendJSONValue(dst, value.values[key])
+		}
+		return append(dst, '}')
+	}
+	return append(dst, "null"...)
+}
+
+// filterJSON applies a filter expression to the JSON values of data and returns
+// every result as formatted JSON document, one after another
+func filterJSON(data []byte, expression string, indent string) (string, error) {
+	filter, err := compileJSONFilter(expression)
+	if err != nil {
+		return "", err
+	}
+	values, err := parseJSONValues(data)
+	if err != nil {
+		return "", err
+	}
+	var results []string
+	for _, value := range values {
+		outputs, err := filter(value, value)
+		if err != nil {
+			return "", err
+		}
+		for _, output := range outputs {
+			formatted, _ := formatJSON(appendJSONValue(nil, output), indent)
+			results = append(results, formatted)
+		}
+	}
+	return strings.Join(results, "\r\n"), nil
+}
+
+// jsonFilter produces the outputs of an expression for an input value.
+// root is the document "$" refers to.
+type jsonFilter func(root, input any) ([]any, error)
+
+// compileJSONFilter parses a jq-style expression like `.items[] | select(.status == "failed") | .id`.
+// JSONPath expressions like `$.items[?(@.status == 'failed')].id` are understood as well.
+func compileJSONFilter(expression string) (jsonFilter, error) {
+	tokens, err := tokenizeJQ(expression)
+	if err != nil {
+		return nil, err
+	}
+	if len(tokens) == 0 {
+		return identityFilter, nil
+	}
+	p := &jqParser{tokens: tokens}
+	filter, err := p.parsePipe(true)
+	if err != nil {
+		return nil, err
+	}
+	if !p.done() {
+		return nil, fmt.Errorf("unexpected %q", p.peek())
+	}
+	return filter, nil
+}
+
+func identityFilter(root, input any) ([]any, error) {
+	return []any{input}, nil
+}
+
+// tokenizeJQ splits a filter expression into fields, identifiers, numbers, strings and symbols
+func tokenizeJQ(src string) ([]string, error) {
+	isIdentStart := func(c byte) bool {
+		return c == '_' || (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z')
+	}
+	isIdent := func(c byte) bool {
+		return isIdentStart(c) || (c >= '0' && c <= '9')
+	}
+	var tokens []string
+	for i := 0; i < len(src); {
+		c := src[i]
+		switch {
+		case c == ' ' || c == '\t' || c == '\r' || c == '\n':
+			i++
+		case c == '#':
+			end := strings.IndexByte(src[i:], '\n')
+			if end < 0 {
+				end = len(src) - i
+			}
+			i += end
+		case c == '"' || c == '\'':
+			end := i + 1
+			for end < len(src) && src[end] != c {
+				if src[end] == '\\' {
+					end++
+				}
+				end++
+			}
+			if end >= len(src) {
+				return nil, fmt.Errorf("unterminated string")
+			}
+			tokens = append(tokens, src[i:end+1])
+			i = end + 1
+		case c == '.':
+			// A field name directly after the dot belongs to it: ".name" and "..name"
+			end := i + 1
+			if end < len(src) && src[end] == '.' {
+				end++
+			}
+			if end < len(src) && isIdentStart(src[end]) {
+				for end < len(src) && isIdent(src[end]) {
+					end++
+				}
+			}
+			tokens = append(tokens, src[i:end])
+			i = end
+		case c >= '0' && c <= '9':
+			end := i
+			for end < len(src) && (src[end] >= '0' && src[end] <= '9' || src[end] == '.' || src[end] == 'e' || src[end] == 'E' ||
+				(src[end] == '-' || src[end] == '+') && (src[end-1] == 'e' || src[end-1] == 'E')) {
+				end++
+			}
+			tokens = append(tokens, src[i:end])
+			i = end
+		case isIdentStart(c):
+			end := i
+			for end < len(src) && isIdent(src[end]) {
+				end++
+			}
+			tokens = append(tokens, src[i:end])
+			i = end
+		default:
+			if i+1 < len(src) {
+				switch op := src[i : i+2]; op {
+				case "==", "!=", "<=", ">=", "//", "&&", "||":
+					tokens = append(tokens, op)
+					i += 2
+					continue
+				}
+			}
+			if !strings.ContainsRune("[](){}|,:;?<>+-*/%$@", rune(c)) {
+				return nil, fmt.Errorf("unexpected character %q", c)
+			}
+			tokens = append(tokens, string(c))
+			i++
+		}
+	}
+	return tokens, nil
+}
+
+// jqParser compiles the tokens of a filter expression into nested filter functions.
+// It understands paths, iteration, slices, pipes, commas, comparisons, arithmetic,
+// "and", "or", "//", "if", array and object construction and the common builtins.
+// Variables, "reduce" and string interpolation are not supported.
+type jqParser struct {
+	tokens []string
+	pos    int
+}
+
+func (p *jqParser) done() bool {
+	return p.pos >= len(p.tokens)
+}
+
+func (p *jqParser) next() string {
+	if p.done() {
+		return ""
+	}
+	token := p.tokens[p.pos]
+	p.pos++
+	return token
+}
+
+func (p *jqParser) peek() string {
+	if p.done() {
+		return ""
+	}
+	return p.tokens[p.pos]
+}
+
+func (p *jqParser) expect(token string) error {
+	if got := p.next(); got != token {
+		if got == "" {
+			return fmt.Errorf("expected %q at the end", token)
+		}
+		return fmt.Errorf("expected %q, found %q", token, got)
+	}
+	return nil
+}
+
+// isJQString reports whether a token is a string literal
+func isJQString(token string) bool {
+	return token != "" && (token[0] == '"' || token[0] == '\'')
+}
+
+// jqString decodes a string literal. Double quotes follow JSON, single quotes as used
+// by JSONPath only escape the quote and the backslash.
+func jqString(token string) (string, error) {
+	if token[0] == '"' {
+		var s string
+		if err := json.Unmarshal([]byte(token), &s); err != nil {
+			return "", fmt.Errorf("invalid string %s", token)
+		}
+		return s, nil
+	}
+	var builder strings.Builder
+	for i := 1; i < len(token)-1; i++ {
+		if token[i] == '\\' && i+1 < len(token)-1 {
+			i++
+		}
+		builder.WriteByte(token[i])
+	}
+	return builder.String(), nil
+}
+
+// parsePipe parses filters joined by "|". Object values do not allow commas.
+func (p *jqParser) parsePipe(commas bool) (jsonFilter, error) {
+	var left jsonFilter
+	var err error
+	if commas {
+		left, err = p.parseComma()
+	} else {
+		left, err = p.parseAlternative()
+	}
+	if err != nil {
+		return nil, err
+	}
+	if p.peek() != "|" {
+		return left, nil
+	}
+	p.next()
+	right, err := p.parsePipe(commas)
+	if err != nil {
+		return nil, err
+	}
+	return pipeFilters(left, right), nil
+}
+
+// pipeFilters feeds every output of left into right. On an error the outputs produced
+// before it are returned with it, which "?" keeps.
+func pipeFilters(left, right jsonFilter) jsonFilter {
+	return func(root, input any) ([]any, error) {
+		values, leftErr := left(root, input)
+		var outputs []any
+		for _, value := range values {
+			results, err := right(root, value)
+			outputs = append(outputs, results...)
+			if err != nil {
+				return outputs, err
+			}
+		}
+		return outputs, leftErr
+	}
+}
+
+// parseComma parses filters joined by ",", their outputs are concatenated
+func (p *jqParser) parseComma() (jsonFilter, error) {
+	filters := []jsonFilter{}
+	for {
+		filter, err := p.parseAlternative()
+		if err != nil {
+			return nil, err
+		}
+		filters = append(filters, filter)
+		if p.peek() != "," {
+			break
+		}
+		p.next()
+	}
+	if len(filters) == 1 {
+		return filters[0], nil
+	}
+	return func(root, input any) ([]any, error) {
+		var outputs []any
+		for _, filter := range filters {
+			values, err := filter(root, input)
+			outputs = append(outputs, values...)
+			if err != nil {
+				return outputs, err
+			}
+		}
+		return outputs, nil
+	}, nil
+}
+
+// parseAlternative parses "a // b", which outputs b if a has no output other than false and null
+func (p *jqParser) parseAlternative() (jsonFilter, error) {
+	left, err := p.parseOr()
+	if err != nil || p.peek() != "//" {
+		return left, err
+	}
+	p.next()
+	right, err := p.parseAlternative()
+	if err != nil {
+		return nil, err
+	}
+	return func(root, input any) ([]any, error) {
+		// Errors of the left side count as no output
+		values, _ := left(root, input)
+		var outputs []any
+		for _, value := range values {
+			if jsonTruthy(value) {
+				outputs = append(outputs, value)
+			}
+		}
+		if len(outputs) > 0 {
+			return outputs, nil
+		}
+		return right(root, input)
+	}, nil
+}
+
+// parseOr parses "or", also written "||"
+func (p *jqParser) parseOr() (jsonFilter, error) {
+	left, err := p.parseAnd()
+	for err == nil && (p.peek() == "or" || p.peek() == "||") {
+		p.next()
+		var right jsonFilter
+		if right, err = p.parseAnd(); err == nil {
+			left = logicFilter(left, right, true)
+		}
+	}
+	return left, err
+}
+
+// parseAnd parses "and", also written "&&"
+func (p *jqParser) parseAnd() (jsonFilter, error) {
+	left, err := p.parseComparison()
+	for err == nil && (p.peek() == "and" || p.peek() == "&&") {
+		p.next()
+		var right jsonFilter
+		if right, err = p.parseComparison(); err == nil {
+			left = logicFilter(left, right, false)
+		}
+	}
+	return left, err
+}
+
+// logicFilter evaluates right only where left does not decide the result
+func logicFilter(left, right jsonFilter, or bool) jsonFilter {
+	return func(root, input any) ([]any, error) {
+		values, err := left(root, input)
+		if err != nil {
+			return nil, err
+		}
+		var outputs []any
+		for _, value := range values {
+			if jsonTruthy(value) == or {
+				outputs = append(outputs, or)
+				continue
+			}
+			results, err := right(root, input)
+			if err != nil {
+				return nil, err
+			}
+			for _, result := range results {
+				outputs = append(outputs, jsonTruthy(result))
+			}
+		}
+		return outputs, nil
+	}
+}
+
+// jqComparisons are the comparison operators, applied to the order of jsonCompare
+var jqComparisons = map[string]func(int) bool{
+	"==": func(c int) bool { return c == 0 },
+	"!=": func(c int) bool { return c != 0 },
+	"<":  func(c int) bool { return c < 0 },
+	"<=": func(c int) bool { return c <= 0 },
+	">":  func(c int) bool { return c > 0 },
+	">=": func(c int) bool { return c >= 0 },
+}
+
+func (p *jqParser) parseComparison() (jsonFilter, error) {
+	left, err := p.parseAdditive()
+	if err != nil {
+		return nil, err
+	}
+	test, ok := jqComparisons[p.peek()]
+	if !ok {
+		return left, nil
+	}
+	p.next()
+	right, err := p.parseAdditive()
+	if err != nil {
+		return nil, err
+	}
+	return binaryFilter(left, right, func(a, b any) (any, error) {
+		return test(jsonCompare(a, b)), nil
+	}), nil
+}
+
+func (p *jqParser) parseAdditive() (jsonFilter, error) {
+	left, err := p.parseMultiplicative()
+	for err == nil && (p.peek() == "+" || p.peek() == "-") {
+		op := jsonAdd
+		if p.next() == "-" {
+			op = jsonSubtract
+		}
+		var right jsonFilter
+		if right, err = p.parseMultiplicative(); err == nil {
+			left = binaryFilter(left, right, op)
+		}
+	}
+	return left, err
+}
+
+func (p *jqParser) parseMultiplicative() (jsonFilter, error) {
+	left, err := p.parsePostfix()
+	for err == nil && (p.peek() == "*" || p.peek() == "/" || p.peek() == "%") {
+		op := p.next()
+		var right jsonFilter
+		if right, err = p.parsePostfix(); err == nil {
+			left = binaryFilter(left, right, func(a, b any) (any, error) {
+				return jsonArithmetic(op, a, b)
+			})
+		}
+	}
+	return left, err
+}
+
+// binaryFilter applies an operator to every combination of the outputs of both sides
+func binaryFilter(left, right jsonFilter, op func(a, b any) (any, error)) jsonFilter {
+	return func(root, input any) ([]any, error) {
+		rights, err := right(root, input)
+		if err != nil {
+			return nil, err
+		}
+		lefts, err := left(root, input)
+		if err != nil {
+			return nil, err
+		}
+		var outputs []any
+		for _, b := range rights {
+			for _, a := range lefts {
+				result, err := op(a, b)
+				if err != nil {
+					return nil, err
+				}
+				outputs = append(outputs, result)
+			}
+		}
+		return outputs, nil
+	}
+}
+
+// parsePostfix parses a term followed by field accesses, indexes, slices and "?"
+func (p *jqParser) parsePostfix() (jsonFilter, error) {
+	filter, err := p.parseTerm()
+	if err != nil {
+		return nil, err
+	}
+	// "?" applies to the last suffix only, the chain before it still fails
+	var chain jsonFilter
+	last := filter
+	for {
+		var suffix jsonFilter
+		token := p.peek()
+		switch {
+		case token == "?":
+			p.next()
+			last = optionalFilter(last)
+			filter = last
+			if chain != nil {
+				filter = pipeFilters(chain, last)
+			}
+			continue
+		case token == "[":
+			p.next()
+			if suffix, err = p.parseBracket(); err != nil {
+				return nil, err
+			}
+		case token == ".":
+			// ".[index]" and ."name" after a term
+			p.next()
+			switch next := p.peek(); {
+			case next == "[":
+				p.next()
+				if suffix, err = p.parseBracket(); err != nil {
+					return nil, err
+				}
+			case isJQString(next):
+				name, err := jqString(p.next())
+				if err != nil {
+					return nil, err
+				}
+				suffix = fieldFilter(name)
+			default:
+				return nil, fmt.Errorf("unexpected %q after \".\"", next)
+			}
+		case strings.HasPrefix(token, "..") && len(token) > 2:
+			p.next()
+			suffix = recursiveFieldFilter(token[2:])
+		case strings.HasPrefix(token, ".") && len(token) > 1 && token != "..":
+			p.next()
+			suffix = fieldFilter(token[1:])
+		default:
+			return filter, nil
+		}
+		chain, last = filter, suffix
+		filter = pipeFilters(chain, last)
+	}
+}
+
+// optionalFilter suppresses the errors of a filter, e.g. indexing a string with ".name?".
+// The outputs produced before an error are kept.
+func optionalFilter(filter jsonFilter) jsonFilter {
+	return func(root, input any) ([]any, error) {
+		outputs, _ := filter(root, input)
+		return outputs, nil
+	}
+}
+
+func fieldFilter(name string) jsonFilter {
+	return func(root, input any) ([]any, error) {
+		value, err := jsonIndex(input, name)
+		if err != nil {
+			return nil, err
+		}
+		return []any{value}, nil
+	}
+}
+
+// recursiveFieldFilter outputs the values of the field anywhere in the input, JSONPath "..name"
+func recursiveFieldFilter(name string) jsonFilter {
+	return func(root, input any) ([]any, error) {
+		var outputs []any
+		for _, value := range jsonRecurse(input, nil) {
+			if object, ok := value.(*jsonObject); ok {
+				if field, ok := object.values[name]; ok {
+					outputs = append(outputs, field)
+				}
+			}
+		}
+		return outputs, nil
+	}
+}
+
+// parseBracket parses the part after "[": "[]" and "[*]" iterate, "[?(cond)]" selects
+// elements, "[from:to]" slices and "[index]" indexes arrays and objects
+func (p *jqParser) parseBracket() (jsonFilter, error) {
+	switch p.peek() {
+	case "]":
+		p.next()
+		return iterateFilter, nil
+	case "*":
+		p.next()
+		return iterateFilter, p.expect("]")
+	case "?":
+		p.next()
+		if err := p.expect("("); err != nil {
+			return nil, err
+		}
+		condition, err := p.parsePipe(true)
+		if err != nil {
+			return nil, err
+		}
+		if err := p.expect(")"); err != nil {
+			return nil, err
+		}
+		return pipeFilters(iterateFilter, selectFilter(condition)), p.expect("]")
+	}
+	var from, to jsonFilter
+	var err error
+	if p.peek() != ":" {
+		if from, err = p.parsePipe(true); err != nil {
+			return nil, err
+		}
+		if p.peek() == "]" {
+			p.next()
+			return func(root, input any) ([]any, error) {
+				// The index is evaluated on the same input as the indexed value
+				keys, err := from(root, input)
+				if err != nil {
+					return nil, err
+				}
+				var outputs []any
+				for _, key := range keys {
+					value, err := jsonIndex(input, key)
+					if err != nil {
+						return nil, err
+					}
+					outputs = append(outputs, value)
+				}
+				return outputs, nil
+			}, nil
+		}
+	}
+	if err := p.expect(":"); err != nil {
+		return nil, err
+	}
+	if p.peek() != "]" {
+		if to, err = p.parsePipe(true); err != nil {
+			return nil, err
+		}
+	}
+	if err := p.expect("]"); err != nil {
+		return nil, err
+	}
+	bound := func(filter jsonFilter, root, input any) ([]any, error) {
+		if filter == nil {
+			return []any{nil}, nil
+		}
+		return filter(root, input)
+	}
+	return func(root, input any) ([]any, error) {
+		froms, err := bound(from, root, input)
+		if err != nil {
+			return nil, err
+		}
+		tos, err := bound(to, root, input)
+		if err != nil {
+			return nil, err
+		}
+		var outputs []any
+		for _, end := range tos {
+			for _, start := range froms {
+				value, err := jsonSlice(input, start, end)
+				if err != nil {
+					return nil, err
+				}
+				outputs = append(outputs, value)
+			}
+		}
+		return outputs, nil
+	}, nil
+}
+
+// iterateFilter outputs the elements of an array or the values of an object
+func iterateFilter(root, input any) ([]any, error) {
+	switch input := input.(type) {
+	case []any:
+		return input, nil
+	case *jsonObject:
+		outputs := make([]any, 0, len(input.keys))
+		for _, key := range input.keys {
+			outputs = append(outputs, input.values[key])
+		}
+		return outputs, nil
+	}
+	return nil, fmt.Errorf("cannot iterate over %s", jsonType(input))
+}
+
+// selectFilter outputs the input if the condition is true
+func selectFilter(condition jsonFilter) jsonFilter {
+	return func(root, input any) ([]any, error) {
+		values, err := condition(root, input)
+		if err != nil {
+			return nil, err
+		}
+		var outputs []any
+		for _, value := range values {
+			if jsonTruthy(value) {
+				outputs = append(outputs, input)
+			}
+		}
+		return outputs, nil
+	}
+}
+
+// constantFilter outputs a literal
+func constantFilter(value any) jsonFilter {
+	return func(root, input any) ([]any, error) {
+		return []any{value}, nil
+	}
+}
+
+// parseTerm parses paths, literals, parentheses, constructions, "if" and function calls
+func (p *jqParser) parseTerm() (jsonFilter, error) {
+	token := p.next()
+	switch {
+	case token == "":
+		return nil, errors.New("unexpected end of the filter")
+	case token == ".":
+		if isJQString(p.peek()) {
+			name, err := jqString(p.next())
+			if err != nil {
+				return nil, err
+			}
+			return fieldFilter(name), nil
+		}
+		return identityFilter, nil
+	case token == "..":
+		return func(root, input any) ([]any, error) {
+			return jsonRecurse(input, nil), nil
+		}, nil
+	case strings.HasPrefix(token, ".."):
+		return recursiveFieldFilter(token[2:]), nil
+	case strings.HasPrefix(token, "."):
+		return fieldFilter(token[1:]), nil
+	case token == "$":
+		// The JSONPath root
+		return func(root, input any) ([]any, error) {
+			return []any{root}, nil
+		}, nil
+	case token == "@":
+		// The current element of a JSONPath filter
+		return identityFilter, nil
+	case isJQString(token):
+		s, err := jqString(token)
+		if err != nil {
+			return nil, err
+		}
+		return constantFilter(s), nil
+	case token[0] >= '0' && token[0] <= '9':
+		if _, err := strconv.ParseFloat(token, 64); err != nil {
+			return nil, fmt.Errorf("invalid number %q", token)
+		}
+		return constantFilter(json.Number(token)), nil
+	case token == "-":
+		operand, err := p.parsePostfix()
+		if err != nil {
+			return nil, err
+		}
+		return binaryFilter(constantFilter(0.0), operand, jsonSubtract), nil
+	case token == "(":
+		filter, err := p.parsePipe(true)
+		if err != nil {
+			return nil, err
+		}
+		return filter, p.expect(")")
+	case token == "[":
+		return p.parseArray()
+	case token == "{":
+		return p.parseObject()
+	case token == "if":
+		return p.parseIf()
+	case token == "true" || token == "false":
+		return constantFilter(token == "true"), nil
+	case token == "null":
+		return constantFilter(nil), nil
+	case isJQIdent(token):
+		return p.parseCall(token)
+	}
+	return nil, fmt.Errorf("unexpected %q", token)
+}
+
+// isJQIdent reports whether a token is an identifier
+func isJQIdent(token string) bool {
+	c := token[0]
+	return c == '_' || (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z')
+}
+
+// parseArray parses "[...]", which collects all outputs into an array
+func (p *jqParser) parseArray() (jsonFilter, error) {
+	if p.peek() == "]" {
+		p.next()
+		return func(root, input any) ([]any, error) {
+			return []any{[]any{}}, nil
+		}, nil
+	}
+	filter, err := p.parsePipe(true)
+	if err != nil {
+		return nil, err
+	}
+	if err := p.expect("]"); err != nil {
+		return nil, err
+	}
+	return func(root, input any) ([]any, error) {
+		values, err := filter(root, input)
+		if err != nil {
+			return nil, err
+		}
+		return []any{append([]any{}, values...)}, nil
+	}, nil
+}
+
+// parseObject parses "{...}". Keys are names, strings or "(expression)";
+// a name alone like {id} takes the field of the same name.
+func (p *jqParser) parseObject() (jsonFilter, error) {
+	type entry struct {
+		key, value jsonFilter
+	}
+	var entries []entry
+	for p.peek() != "}" {
+		var key, value jsonFilter
+		token := p.next()
+		switch {
+		case token == "(":
+			filter, err := p.parsePipe(true)
+			if err != nil {
+				return nil, err
+			}
+			if err := p.expect(")"); err != nil {
+				return nil, err
+			}
+			key = filter
+		case isJQString(token):
+			name, err := jqString(token)
+			if err != nil {
+				return nil, err
+			}
+			key, value = constantFilter(name), fieldFilter(name)
+		case token != "" && isJQIdent(token):
+			key, value = constantFilter(token), fieldFilter(token)
+		default:
+			return nil, fmt.Errorf("unexpected %q in object", token)
+		}
+		if p.peek() == ":" {
+			p.next()
+			filter, err := p.parsePipe(false)
+			if err != nil {
+				return nil, err
+			}
+			value = filter
+		} else if value == nil {
+			return nil, errors.New("expected \":\" after a computed key")
+		}
+		entries = append(entries, entry{key, value})
+		if p.peek() != "," {
+			break
+		}
+		p.next()
+	}
+	if err := p.expect("}"); err != nil {
+		return nil, err
+	}
+	return func(root, input any) ([]any, error) {
+		// Several outputs of keys or values produce one object for every combination
+		objects := []*jsonObject{newJSONObject()}
+		for _, entry := range entries {
+			keys, err := entry.key(root, input)
+			if err != nil {
+				return nil, err
+			}
+			values, err := entry.value(root, input)
+			if err != nil {
+				return nil, err
+			}
+			var combined []*jsonObject
+			for _, object := range objects {
+				for _, key := range keys {
+					name, ok := key.(string)
+					if !ok {
+						return nil, fmt.Errorf("object keys must be strings, not %s", jsonType(key))
+					}
+					for _, value := range values {
+						extended := object.clone()
+						extended.set(name, value)
+						combined = append(combined, extended)
+					}
+				}
+			}
+			objects = combined
+		}
+		outputs := make([]any, len(objects))
+		for i, object := range objects {
+			outputs[i] = object
+		}
+		return outputs, nil
+	}, nil
+}
+
+// parseIf parses "if cond then a elif cond then b else c end"; without else the input is output
+func (p *jqParser) parseIf() (jsonFilter, error) {
+	condition, err := p.parsePipe(true)
+	if err != nil {
+		return nil, err
+	}
+	if err := p.expect("then"); err != nil {
+		return nil, err
+	}
+	then, err := p.parsePipe(true)
+	if err != nil {
+		return nil, err
+	}
+	otherwise := jsonFilter(identityFilter)
+	switch p.next() {
+	case "elif":
+		if otherwise, err = p.parseIf(); err != nil {
+			return nil, err
+		}
+	case "else":
+		if otherwise, err = p.parsePipe(true); err != nil {
+			return nil, err
+		}
+		if err := p.expect("end"); err != nil {
+			return nil, err
+		}
+	case "end":
+	default:
+		return nil, errors.New("expected \"elif\", \"else\" or \"end\"")
+	}
+	return func(root, input any) ([]any, error) {
+		values, err := condition(root, input)
+		if err != nil {
+			return nil, err
+		}
+		var outputs []any
+		for _, value := range values {
+			branch := otherwise
+			if jsonTruthy(value) {
+				branch = then
+			}
+			results, err := branch(root, input)
+			if err != nil {
+				return nil, err
+			}
+			outputs = append(outputs, results...)
+		}
+		return outputs, nil
+	}, nil
+}
+
+// jqBuiltins are the functions without arguments that map the input to one value
+var jqBuiltins = map[string]func(input any) (any, error){
+	"length":         jsonLength,
+	"keys":           func(input any) (any, error) { return jsonKeys(input, true) },
+	"keys_unsorted":  func(input any) (any, error) { return jsonKeys(input, false) },
+	"not":            func(input any) (any, error) { return !jsonTruthy(input), nil },
+	"type":           func(input any) (any, error) { return jsonType(input), nil },
+	"add":            jsonAddAll,
+	"sort":           func(input any) (any, error) { return jsonSortBy(input, nil) },
+	"unique":         func(input any) (any, error) { return jsonUniqueBy(input, nil) },
+	"min":            func(input any) (any, error) { return jsonExtreme(input, -1) },
+	"max":            func(input any) (any, error) { return jsonExtreme(input, 1) },
+	"reverse":        jsonReverse,
+	"first":          func(input any) (any, error) { return jsonIndex(input, 0.0) },
+	"last":           func(input any) (any, error) { return jsonIndex(input, -1.0) },
+	"flatten":        func(input any) (any, error) { return jsonFlatten(input) },
+	"any":            func(input any) (any, error) { return jsonQuantify(input, true) },
+	"all":            func(input any) (any, error) { return jsonQuantify(input, false) },
+	"to_entries":     jsonToEntries,
+	"from_entries":   jsonFromEntries,
+	"tostring":       jsonToString,
+	"tonumber":       jsonToNumber,
+	"ascii_downcase": func(input any) (any, error) { return jsonMapString(input, strings.ToLower) },
+	"ascii_upcase":   func(input any) (any, error) { return jsonMapString(input, strings.ToUpper) },
+	"floor":          func(input any) (any, error) { return jsonMapNumber(input, math.Floor) },
+	"ceil":           func(input any) (any, error) { return jsonMapNumber(input, math.Ceil) },
+	"round":          func(input any) (any, error) { return jsonMapNumber(input, math.Round) },
+}
+
+// parseCall parses a builtin function, arguments are separated by ";"
+func (p *jqParser) parseCall(name string) (jsonFilter, error) {
+	var args []jsonFilter
+	if p.peek() == "(" {
+		p.next()
+		for {
+			arg, err := p.parsePipe(true)
+			if err != nil {
+				return nil, err
+			}
+			args = append(args, arg)
+			if p.peek() != ";" {
+				break
+			}
+			p.next()
+		}
+		if err := p.expect(")"); err != nil {
+			return nil, err
+		}
+	}
+	if len(args) == 0 {
+		if builtin, ok := jqBuiltins[name]; ok {
+			return func(root, input any) ([]any, error) {
+				value, err := builtin(input)
+				if err != nil {
+					return nil, err
+				}
+				return []any{value}, nil
+			}, nil
+		}
+		switch name {
+		case "empty":
+			return func(root, input any) ([]any, error) {
+				return nil, nil
+			}, nil
+		case "values":
+			return selectFilter(func(root, input any) ([]any, error) {
+				return []any{input != nil}, nil
+			}), nil
+		case "nulls", "booleans", "numbers", "strings", "arrays", "objects":
+			// Type selectors like "numbers" keep inputs of the type
+			typeName := map[string]string{"nulls": "null", "booleans": "boolean"}[name]
+			if typeName == "" {
+				typeName = strings.TrimSuffix(name, "s")
+			}
+			return selectFilter(func(root, input any) ([]any, error) {
+				return []any{jsonType(input) == typeName}, nil
+			}), nil
+		case "recurse":
+			return func(root, input any) ([]any, error) {
+				return jsonRecurse(input, nil), nil
+			}, nil
+		}
+	}
+	if len(args) == 1 {
+		arg := args[0]
+		switch name {
+		case "select":
+			return selectFilter(arg), nil
+		case "map":
+			return pipeFilters(iterateFilter, arg).collect(), nil
+		case "with_entries":
+			return func(root, input any) ([]any, error) {
+				entries, err := jsonToEntries(input)
+				if err != nil {
+					return nil, err
+				}
+				mapped, err := pipeFilters(iterateFilter, arg).collect()(root, entries)
+				if err != nil {
+					return nil, err
+				}
+				object, err := jsonFromEntries(mapped[0])
+				return []any{object}, err
+			}, nil
+		case "sort_by", "group_by", "unique_by", "min_by", "max_by":
+			return func(root, input any) ([]any, error) {
+				keyOf := func(element any) (any, error) {
+					values, err := arg(root, element)
+					return append([]any{}, values...), err
+				}
+				var value any
+				var err error
+				switch name {
+				case "sort_by":
+					value, err = jsonSortBy(input, keyOf)
+				case "group_by":
+					value, err = jsonGroupBy(input, keyOf)
+				case "unique_by":
+					value, err = jsonUniqueBy(input, keyOf)
+				case "min_by":
+					value, err = jsonExtremeBy(input, keyOf, -1)
+				default:
+					value, err = jsonExtremeBy(input, keyOf, 1)
+				}
+				if err != nil {
+					return nil, err
+				}
+				return []any{value}, nil
+			}, nil
+		case "any", "all":
+			return func(root, input any) ([]any, error) {
+				mapped, err := pipeFilters(iterateFilter, arg).collect()(root, input)
+				if err != nil {
+					return nil, err
+				}
+				value, err := jsonQuantify(mapped[0], name == "any")
+				return []any{value}, err
+			}, nil
+		}
+		// Functions of the input and a value, applied to every output of the argument
+		var apply func(input, value any) (any, error)
+		switch name {
+		case "has":
+			apply = jsonHas
+		case "contains":
+			apply = func(input, value any) (any, error) { return jsonContains(input, value), nil }
+		case "test":
+			apply = jsonTest
+		case "startswith":
+			apply = func(input, value any) (any, error) { return jsonStringTest(input, value, strings.HasPrefix) }
+		case "endswith":
+			apply = func(input, value any) (any, error) { return jsonStringTest(input, value, strings.HasSuffix) }
+		case "split":
+			apply = jsonSplit
+		case "join":
+			apply = jsonJoin
+		}
+		if apply != nil {
+			return func(root, input any) ([]any, error) {
+				values, err := arg(root, input)
+				if err != nil {
+					return nil, err
+				}
+				var outputs []any
+				for _, value := range values {
+					result, err := apply(input, value)
+					if err != nil {
+						return nil, err
+					}
+					outputs = append(outputs, result)
+				}
+				return outputs, nil
+			}, nil
+		}
+	}
+	return nil, fmt.Errorf("unknown function %s/%d", name, len(args))
+}
+
+// collect wraps all outputs of the filter into one array, as "[f]" does
+func (filter jsonFilter) collect() jsonFilter {
+	return func(root, input any) ([]any, error) {
+		values, err := filter(root, input)
+		if err != nil {
+			return nil, err
+		}
+		return []any{append([]any{}, values...)}, nil
+	}
+}
+
+// jsonType returns the jq name of the type of a value
+func jsonType(value any) string {
+	switch value.(type) {
+	case nil:
+		return "null"
+	case bool:
+		return "boolean"
+	case json.Number, float64:
+		return "number"
+	case string:
+		return "string"
+	case []any:
+		return "array"
+	}
+	return "object"
+}
+
+// jsonTruthy reports whether a value counts as true; only false and null do not
+func jsonTruthy(value any) bool {
+	return value != nil && value != false
+}
+
+// jsonNumber returns the value of a number
+func jsonNumber(value any) (float64, bool) {
+	switch value := value.(type) {
+	case json.Number:
+		f, err := strconv.ParseFloat(string(value), 64)
+		// Out of range numbers are returned as infinity
+		return f, err == nil || errors.Is(err, strconv.ErrRange)
+	case float64:
+		return value, true
+	}
+	return 0, false
+}
+
+// jsonInt returns a number as index, rounded down
+func jsonInt(value any) (int, bool) {
+	f, ok := jsonNumber(value)
+	if !ok || math.IsNaN(f) {
+		return 0, false
+	}
+	return int(math.Max(math.Min(math.Floor(f), math.MaxInt32), math.MinInt32)), true
+}
+
+// jsonRank orders the types: null, false, true, numbers, strings, arrays, objects
+func jsonRank(value any) int {
+	switch value := value.(type) {
+	case nil:
+		return 0
+	case bool:
+		if value {
+			return 2
+		}
+		return 1
+	case json.Number, float64:
+		return 3
+	case string:
+		return 4
+	case []any:
+		return 5
+	}
+	return 6
+}
+
+// jsonCompare orders two values the way jq does. Objects compare their sorted keys first,
+// then the values of those keys.
+func jsonCompare(a, b any) int {
+	if rankA, rankB := jsonRank(a), jsonRank(b); rankA != rankB {
+		return cmp.Compare(rankA, rankB)
+	}
+	switch a := a.(type) {
+	case string:
+		return strings.Compare(a, b.(string))
+	case []any:
+		b := b.([]any)
+		for i := range min(len(a), len(b)) {
+			if c := jsonCompare(a[i], b[i]); c != 0 {
+				return c
+			}
+		}
+		return cmp.Compare(len(a), len(b))
+	case *jsonObject:
+		b := b.(*jsonObject)
+		keysA, keysB := slices.Sorted(slices.Values(a.keys)), slices.Sorted(slices.Values(b.keys))
+		if c := slices.Compare(keysA, keysB); c != 0 {
+			return c
+		}
+		for _, key := range keysA {
+			if c := jsonCompare(a.values[key], b.values[key]); c != 0 {
+				return c
+			}
+		}
+		return 0
+	case json.Number:
+		// Equal literals are equal even beyond the precision of a float
+		if b, ok := b.(json.Number); ok && a == b {
+			return 0
+		}
+	}
+	x, _ := jsonNumber(a)
+	y, _ := jsonNumber(b)
+	return cmp.Compare(x, y)
+}
+
+// jsonIndex returns the field of an object or the element of an array; negative
+// indexes count from the end. Missing fields, indexes out of range and null give null.
+func jsonIndex(value, key any) (any, error) {
+	if value == nil {
+		return nil, nil
+	}
+	if name, ok := key.(string); ok {
+		if object, ok := value.(*jsonObject); ok {
+			return object.values[name], nil
+		}
+		return nil, fmt.Errorf("cannot index %s with %q", jsonType(value), name)
+	}
+	if index, ok := jsonInt(key); ok {
+		if array, ok := value.([]any); ok {
+			if index < 0 {
+				index += len(array)
+			}
+			if index < 0 || index >= len(array) {
+				return nil, nil
+			}
+			return array[index], nil
+		}
+		return nil, fmt.Errorf("cannot index %s with a number", jsonType(value))
+	}
+	return nil, fmt.Errorf("cannot index %s with %s", jsonType(value), jsonType(key))
+}
+
+// jsonSlice returns part of an array or string; null bounds stand for the start and the end
+func jsonSlice(value, from, to any) (any, error) {
+	var length int
+	switch value := value.(type) {
+	case nil:
+		return nil, nil
+	case []any:
+		length = len(value)
+	case string:
+		length = utf8.RuneCountInString(value)
+	default:
+		return nil, fmt.Errorf("cannot slice %s", jsonType(value))
+	}
+	bound := func(index any, fallback int) (int, error) {
+		if index == nil {
+			return fallback, nil
+		}
+		n, ok := jsonInt(index)
+		if !ok {
+			return 0, fmt.Errorf("slice bounds must be numbers, not %s", jsonType(index))
+		}
+		if n < 0 {
+			n += length
+		}
+		return min(max(n, 0), length), nil
+	}
+	start, err := bound(from, 0)
+	if err != nil {
+		return nil, err
+	}
+	end, err := bound(to, length)
+	if err != nil {
+		return nil, err
+	}
+	end = max(end, start)
+	if s, ok := value.(string); ok {
+		return string([]rune(s)[start:end]), nil
+	}
+	return slices.Clone(value.([]any)[start:end]), nil
+}
+
+// jsonRecurse appends the value and all values nested in it, depth first
+func jsonRecurse(value any, outputs []any) []any {
+	outputs = append(outputs, value)
+	switch value := value.(type) {
+	case []any:
+		for _, element := range value {
+			outputs = jsonRecurse(element, outputs)
+		}
+	case *jsonObject:
+		for _, key := range value.keys {
+			outputs = jsonRecurse(value.values[key], outputs)
+		}
+	}
+	return outputs
+}
+
+// jsonAdd adds numbers, concatenates strings and arrays and merges objects; null is neutral
+func jsonAdd(a, b any) (any, error) {
+	if a == nil {
+		return b, nil
+	}
+	if b == nil {
+		return a, nil
+	}
+	if x, ok := jsonNumber(a); ok {
+		if y, ok := jsonNumber(b); ok {
+			return x + y, nil
+		}
+	}
+	switch a := a.(type) {
+	case string:
+		if b, ok := b.(string); ok {
+			return a + b, nil
+		}
+	case []any:
+		if b, ok := b.([]any); ok {
+			return append(slices.Clone(a), b...), nil
+		}
+	case *jsonObject:
+		if b, ok := b.(*jsonObject); ok {
+			merged := a.clone()
+			for _, key := range b.keys {
+				merged.set(key, b.values[key])
+			}
+			return merged, nil
+		}
+	}
+	return nil, fmt.Errorf("cannot add %s and %s", jsonType(a), jsonType(b))
+}
+
+// jsonSubtract subtracts numbers and removes the elements of b from the array a
+func jsonSubtract(a, b any) (any, error) {
+	if x, ok := jsonNumber(a); ok {
+		if y, ok := jsonNumber(b); ok {
+			return x - y, nil
+		}
+	}
+	if a, ok := a.([]any); ok {
+		if b, ok := b.([]any); ok {
+			return slices.DeleteFunc(slices.Clone(a), func(element any) bool {
+				return slices.ContainsFunc(b, func(removed any) bool { return jsonCompare(element, removed) == 0 })
+			}), nil
+		}
+	}
+	return nil, fmt.Errorf("cannot subtract %s from %s", jsonType(b), jsonType(a))
+}
+
+// jsonArithmetic multiplies, divides or takes the remainder of numbers
+func jsonArithmetic(op string, a, b any) (any, error) {
+	x, okA := jsonNumber(a)
+	y, okB := jsonNumber(b)
+	if !okA || !okB {
+		return nil, fmt.Errorf("cannot apply %s to %s and %s", op, jsonType(a), jsonType(b))
+	}
+	switch op {
+	case "*":
+		return x * y, nil
+	case "/":
+		if y == 0 {
+			return nil, errors.New("division by zero")
+		}
+		return x / y, nil
+	}
+	if int64(y) == 0 {
+		return nil, errors.New("remainder of division by zero")
+	}
+	return float64(int64(x) % int64(y)), nil
+}
+
+// jsonLength returns the length of strings, arrays and objects, and the absolute value of numbers
+func jsonLength(value any) (any, error) {
+	switch value := value.(type) {
+	case nil:
+		return 0.0, nil
+	case string:
+		return float64(utf8.RuneCountInString(value)), nil
+	case []any:
+		return float64(len(value)), nil
+	case *jsonObject:
+		return float64(len(value.keys)), nil
+	}
+	if f, ok := jsonNumber(value); ok {
+		return math.Abs(f), nil
+	}
+	return nil, fmt.Errorf("%s has no length", jsonType(value))
+}
+
+// jsonKeys returns the keys of an object, sorted if requested, or the indexes of an array
+func jsonKeys(value any, sorted bool) (any, error) {
+	switch value := value.(type) {
+	case *jsonObject:
+		keys := slices.Clone(value.keys)
+		if sorted {
+			sort.Strings(keys)
+		}
+		outputs := make([]any, len(keys))
+		for i, key := range keys {
+			outputs[i] = key
+		}
+		return outputs, nil
+	case []any:
+		outputs := make([]any, len(value))
+		for i := range value {
+			outputs[i] = float64(i)
+		}
+		return outputs, nil
+	}
+	return nil, fmt.Errorf("%s has no keys", jsonType(value))
+}
+
+// jsonHas reports whether an object has a key or an array has an index
+func jsonHas(value, key any) (any, error) {
+	switch value := value.(type) {
+	case *jsonObject:
+		if name, ok := key.(string); ok {
+			_, found := value.values[name]
+			return found, nil
+		}
+	case []any:
+		if index, ok := jsonInt(key); ok {
+			return index >= 0 && index < len(value), nil
+		}
+	}
+	return nil, fmt.Errorf("cannot check whether %s has a %s key", jsonType(value), jsonType(key))
+}
+
+// jsonElements returns the elements of an array
+func jsonElements(value any, function string) ([]any, error) {
+	array, ok := value.([]any)
+	if !ok {
+		return nil, fmt.Errorf("%s needs an array, not %s", function, jsonType(value))
+	}
+	return array, nil
+}
+
+// jsonAddAll adds all elements of an array, null for an empty array
+func jsonAddAll(value any) (any, error) {
+	elements, err := jsonElements(value, "add")
+	if err != nil {
+		return nil, err
+	}
+	var sum any
+	for _, element := range elements {
+		if sum, err = jsonAdd(sum, element); err != nil {
+			return nil, err
+		}
+	}
+	return sum, nil
+}
+
+// jsonSortBy sorts an array stable by the keys of its elements, or by the elements themselves
+func jsonSortBy(value any, keyOf func(any) (any, error)) (any, error) {
+	elements, err := jsonElements(value, "sort")
+	if err != nil {
+		return nil, err
+	}
+	type keyed struct {
+		key, element any
+	}
+	sorted := make([]keyed, len(elements))
+	for i, element := range elements {
+		key := element
+		if keyOf != nil {
+			if key, err = keyOf(element); err != nil {
+				return nil, err
+			}
+		}
+		sorted[i] = keyed{key, element}
+	}
+	slices.SortStableFunc(sorted, func(a, b keyed) int { return jsonCompare(a.key, b.key) })
+	outputs := make([]any, len(sorted))
+	for i, entry := range sorted {
+		outputs[i] = entry.element
+	}
+	return outputs, nil
+}
+
+// jsonGroupBy sorts an array by the keys and groups elements with equal keys into arrays
+func jsonGroupBy(value any, keyOf func(any) (any, error)) (any, error) {
+	return jsonGroups(value, keyOf, func(group []any) any { return group })
+}
+
+// jsonUniqueBy keeps the first element of each key, sorted by the keys
+func jsonUniqueBy(value any, keyOf func(any) (any, error)) (any, error) {
+	return jsonGroups(value, keyOf, func(group []any) any { return group[0] })
+}
+
+func jsonGroups(value any, keyOf func(any) (any, error), output func([]any) any) (any, error) {
+	sorted, err := jsonSortBy(value, keyOf)
+	if err != nil {
+		return nil, err
+	}
+	if keyOf == nil {
+		keyOf = func(element any) (any, error) { return element, nil }
+	}
+	outputs := []any{}
+	var group []any
+	var groupKey any
+	for _, element := range sorted.([]any) {
+		key, _ := keyOf(element)
+		if len(group) > 0 && jsonCompare(key, groupKey) != 0 {
+			outputs = append(outputs, output(group))
+			group = nil
+		}
+		group, groupKey = append(group, element), key
+	}
+	if len(group) > 0 {
+		outputs = append(outputs, output(group))
+	}
+	return outputs, nil
+}
+
+// jsonExtreme returns the smallest (sign -1) or largest (sign 1) element, null for an empty array
+func jsonExtreme(value any, sign int) (any, error) {
+	return jsonExtremeBy(value, nil, sign)
+}
+
+func jsonExtremeBy(value any, keyOf func(any) (any, error), sign int) (any, error) {
+	elements, err := jsonElements(value, "min and max")
+	if err != nil {
+		return nil, err
+	}
+	var best, bestKey any
+	for i, element := range elements {
+		key := element
+		if keyOf != nil {
+			if key, err = keyOf(element); err != nil {
+				return nil, err
+			}
+		}
+		// Ties keep the first minimum and the last maximum
+		if c := jsonCompare(key, bestKey) * sign; i == 0 || c > 0 || (c == 0 && sign > 0) {
+			best, bestKey = element, key
+		}
+	}
+	return best, nil
+}
+
+// jsonReverse reverses an array or a string
+func jsonReverse(value any) (any, error) {
+	switch value := value.(type) {
+	case nil:
+		return []any{}, nil
+	case string:
+		runes := []rune(value)
+		slices.Reverse(runes)
+		return string(runes), nil
+	case []any:
+		reversed := slices.Clone(value)
+		slices.Reverse(reversed)
+		return reversed, nil
+	}
+	return nil, fmt.Errorf("cannot reverse %s", jsonType(value))
+}
+
+// jsonFlatten flattens nested arrays completely
+func jsonFlatten(value any) ([]any, error) {
+	elements, err := jsonElements(value, "flatten")
+	if err != nil {
+		return nil, err
+	}
+	outputs := []any{}
+	for _, element := range elements {
+		if _, ok := element.([]any); ok {
+			nested, _ := jsonFlatten(element)
+			outputs = append(outputs, nested...)
+		} else {
+			outputs = append(outputs, element)
+		}
+	}
+	return outputs, nil
+}
+
+// jsonQuantify reports whether any or all elements are true
+func jsonQuantify(value any, wantAny bool) (any, error) {
+	elements, err := jsonElements(value, "any and all")
+	if err != nil {
+		return nil, err
+	}
+	for _, element := range elements {
+		if jsonTruthy(element) == wantAny {
+			return wantAny, nil
+		}
+	}
+	return !wantAny, nil
+}
+
+// jsonToEntries converts an object to an array of {"key", "value"} objects
+func jsonToEntries(value any) (any, error) {
+	object, ok := value.(*jsonObject)
+	if !ok {
+		return nil, fmt.Errorf("to_entries needs an object, not %s", jsonType(value))
+	}
+	entries := make([]any, len(object.keys))
+	for i, key := range object.keys {
+		entry := newJSONObject()
+		entry.set("key", key)
+		entry.set("value", object.values[key])
+		entries[i] = entry
+	}
+	return entries, nil
+}
+
+// jsonFromEntries builds an object from {"key", "value"} objects; "k", "name" and "v" work as well
+func jsonFromEntries(value any) (any, error) {
+	elements, err := jsonElements(value, "from_entries")
+	if err != nil {
+		return nil, err
+	}
+	object := newJSONObject()
+	for _, element := range elements {
+		entry, ok := element.(*jsonObject)
+		if !ok {
+			return nil, fmt.Errorf("from_entries needs objects, not %s", jsonType(element))
+		}
+		var key any
+		for _, name := range []string{"key", "k", "name"} {
+			if key = entry.values[name]; key != nil {
+				break
+			}
+		}
+		var name string
+		switch key := key.(type) {
+		case string:
+			name = key
+		case json.Number, float64, bool:
+			name = string(appendJSONValue(nil, key))
+		default:
+			return nil, fmt.Errorf("from_entries needs string keys, not %s", jsonType(key))
+		}
+		entryValue, ok := entry.values["value"]
+		if !ok {
+			entryValue = entry.values["v"]
+		}
+		object.set(name, entryValue)
+	}
+	return object, nil
+}
+
+// jsonToString returns strings as they are and other values as JSON text
+func jsonToString(value any) (any, error) {
+	if s, ok := value.(string); ok {
+		return s, nil
+	}
+	return string(appendJSONValue(nil, value)), nil
+}
+
+// jsonToNumber parses a string as number
+func jsonToNumber(value any) (any, error) {
+	switch value := value.(type) {
+	case json.Number, float64:
+		return value, nil
+	case string:
+		text := strings.TrimSpace(value)
+		if _, err := strconv.ParseFloat(text, 64); err != nil || !json.Valid([]byte(text)) {
+			return nil, fmt.Errorf("cannot parse %q as number", value)
+		}
+		return json.Number(text), nil
+	}
+	return nil, fmt.Errorf("cannot convert %s to a number", jsonType(value))
+}
+
+func jsonMapString(value any, mapping func(string) string) (any, error) {
+	s, ok := value.(string)
+	if !ok {
+		return nil, fmt.Errorf("expected a string, not %s", jsonType(value))
+	}
+	return mapping(s), nil
+}
+
+func jsonMapNumber(value any, mapping func(float64) float64) (any, error) {
+	f, ok := jsonNumber(value)
+	if !ok {
+		return nil, fmt.Errorf("expected a number, not %s", jsonType(value))
+	}
+	return mapping(f), nil
+}
+
+// jsonContains reports whether b is contained in a: substrings, elements contained in
+// any element of an array and fields contained in the same field of an object
+func jsonContains(a, b any) bool {
+	switch a := a.(type) {
+	case string:
+		if b, ok := b.(string); ok {
+			return strings.Contains(a, b)
+		}
+	case []any:
+		if b, ok := b.([]any); ok {
+			return !slices.ContainsFunc(b, func(wanted any) bool {
+				return !slices.ContainsFunc(a, func(element any) bool { return jsonContains(element, wanted) })
+			})
+		}
+	case *jsonObject:
+		if b, ok := b.(*jsonObject); ok {
+			for _, key := range b.keys {
+				value, found := a.values[key]
+				if !found || !jsonContains(value, b.values[key]) {
+					return false
+				}
+			}
+			return true
+		}
+	}
+	return jsonRank(a) == jsonRank(b) && jsonCompare(a, b) == 0
+}
+
+// jsonTest reports whether a string matches a regular expression
+func jsonTest(value, pattern any) (any, error) {
+	s, ok := value.(string)
+	expr, okPattern := pattern.(string)
+	if !ok || !okPattern {
+		return nil, fmt.Errorf("test needs a string and a pattern, not %s and %s", jsonType(value), jsonType(pattern))
+	}
+	re, err := regexp.Compile(expr)
+	if err != nil {
+		return nil, fmt.Errorf("invalid pattern: %v", err)
+	}
+	return re.MatchString(s), nil
+}
+
+func jsonStringTest(value, arg any, test func(s, prefix string) bool) (any, error) {
+	s, ok := value.(string)
+	other, okArg := arg.(string)
+	if !ok || !okArg {
+		return nil, fmt.Errorf("expected strings, not %s and %s", jsonType(value), jsonType(arg))
+	}
+	return test(s, other), nil
+}
+
+// jsonSplit splits a string at a separator
+func jsonSplit(value, separator any) (any, error) {
+	s, ok := value.(string)
+	sep, okSep := separator.(string)
+	if !ok || !okSep {
+		return nil, fmt.Errorf("split needs strings, not %s and %s", jsonType(value), jsonType(separator))
+	}
+	parts := strings.Split(s, sep)
+	outputs := make([]any, len(parts))
+	for i, part := range parts {
+		outputs[i] = part
+	}
+	return outputs, nil
+}
+
+// jsonJoin joins the elements of an array with a separator; null is empty
+func jsonJoin(value, separator any) (any, error) {
+	elements, err := jsonElements(value, "join")
+	if err != nil {
+		return nil, err
+	}
+	sep, ok := separator.(string)
+	if !ok {
+		return nil, fmt.Errorf("join needs a string separator, not %s", jsonType(separator))
+	}
+	parts := make([]string, len(elements))
+	for i, element := range elements {
+		switch element := element.(type) {
+		case nil:
+		case string:
+			parts[i] = element
+		case bool, json.Number, float64:
+			parts[i] = string(appendJSONValue(nil, element))
+		default:
+			return nil, fmt.Errorf("cannot join %s", jsonType(element))
+		}
+	}
+	return strings.Join(parts, sep), nil
+}
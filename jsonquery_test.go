@@ -0,0 +1,103 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+const jsonQueryDocument = `{
+	"items": [
+		{"id": 1, "status": "ok", "tags": ["a", "b"], "price": 10},
+		{"id": 2, "status": "failed", "tags": "none", "price": 25},
+		{"id": 3, "status": "failed", "tags": ["c"], "price": 5, "note": null}
+	],
+	"meta": {"total": 3, "x": "outer", "nested": {"x": "inner"}}
+}`
+
+func TestFilterJSON(t *testing.T) {
+	tests := []struct {
+		name       string
+		expression string
+		want       string
+	}{
+		{"empty expression", ``, `{"items":[{"id":1,"status":"ok","tags":["a","b"],"price":10},{"id":2,"status":"failed","tags":"none","price":25},{"id":3,"status":"failed","tags":["c"],"price":5,"note":null}],"meta":{"total":3,"x":"outer","nested":{"x":"inner"}}}`},
+		{"select", `.items[] | select(.status=="failed") | .id`, "2\r\n3"},
+		{"field chain", `.meta.nested.x`, `"inner"`},
+		{"collect", `[.items[].id]`, `[1,2,3]`},
+		{"object construction", `.items[0] | {id, status}`, `{"id":1,"status":"ok"}`},
+		{"arithmetic", `.items[0].price * 2 + 1`, `21`},
+		{"comma", `.meta.total, .meta.x`, "3\r\n\"outer\""},
+
+		{"JSONPath recursive field", `$..x`, "\"outer\"\r\n\"inner\""},
+		{"JSONPath filter", `$.items[?(@.status == 'failed')].id`, "2\r\n3"},
+		{"JSONPath wildcard", `$.items[*].id`, "1\r\n2\r\n3"},
+
+		{"optional field", `.items[].tags.name?`, ``},
+		{"optional last suffix", `.items[].tags[]?`, "\"a\"\r\n\"b\"\r\n\"c\""},
+		{"optional keeps earlier outputs", `[.items[] | .tags[0]?]`, `["a","c"]`},
+		{"optional term", `(.meta.total, .meta.x[], .meta.total)?`, `3`},
+
+		{"slice", `.items[1:] | map(.id)`, `[2,3]`},
+		{"slice from end", `.items[-1:][0].id`, `3`},
+		{"slice open start", `.items[:2] | length`, `2`},
+		{"slice string", `.meta.x[1:3]`, `"ut"`},
+
+		{"alternative", `.items[2].note // "none"`, `"none"`},
+		{"alternative first", `.meta.x // "none"`, `"outer"`},
+		{"alternative after error", `(.items[1].tags[0]) // "fallback"`, `"fallback"`},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got, err := filterJSON([]byte(jsonQueryDocument), test.expression, "")
+			if err != nil {
+				t.Fatalf("filterJSON(%q): %v", test.expression, err)
+			}
+			if got != test.want {
+				t.Errorf("filterJSON(%q) = %q, want %q", test.expression, got, test.want)
+			}
+		})
+	}
+}
+
+func TestFilterJSONErrors(t *testing.T) {
+	tests := []struct {
+		name       string
+		expression string
+		want       string // Part of the error message
+	}{
+		{"unclosed bracket", `.items[`, "unexpected end"},
+		{"trailing token", `.items )`, `unexpected ")"`},
+		{"unknown function", `.items | frobnicate`, "unknown function frobnicate/0"},
+		{"bad field after dot", `.items.[`, "unexpected end"},
+		{"iterate a string", `.meta.x[]`, "cannot iterate over string"},
+		{"optional applies to the last suffix only", `.meta.x[].y?`, "cannot iterate over string"},
+		{"division by zero", `.meta.total / 0`, "division by zero"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			_, err := filterJSON([]byte(jsonQueryDocument), test.expression, "")
+			if err == nil || !strings.Contains(err.Error(), test.want) {
+				t.Errorf("filterJSON(%q) error = %v, want %q", test.expression, err, test.want)
+			}
+		})
+	}
+}
+
+func TestFilterJSONBody(t *testing.T) {
+	if _, err := filterJSON([]byte("<html>"), ".", ""); err == nil {
+		t.Error("filtering a non-JSON body succeeded")
+	}
+	// NDJSON is filtered line by line
+	got, err := filterJSON([]byte("{\"a\":1}\n{\"a\":2}\n"), ".a", "")
+	if err != nil || got != "1\r\n2" {
+		t.Errorf("filtering NDJSON = %q, %v", got, err)
+	}
+}
+
+func TestCompileJSONFilter(t *testing.T) {
+	for _, expression := range []string{`.a |`, `[1, 2`, `{a: }`, `if . then 1`, `"unterminated`} {
+		if _, err := compileJSONFilter(expression); err == nil {
+			t.Errorf("compileJSONFilter(%q) succeeded", expression)
+		}
+	}
+}
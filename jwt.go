@@ -0,0 +1,262 @@
+package main
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"os"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// jwtPattern matches compact JWS tokens; header and payload always start with a base64url encoded '{"'
+var jwtPattern = regexp.MustCompile(`eyJ[A-Za-z0-9_-]+\.eyJ[A-Za-z0-9_-]+\.[A-Za-z0-9_-]*`)
+
+// JWTInfo is the decoded, unverified content of a JWT
+type JWTInfo struct {
+	Token  string         // The raw token
+	Header map[string]any // Decoded JOSE header
+	Claims map[string]any // Decoded claims
+}
+
+// findJWTs returns all distinct JWTs contained in the given text
+func findJWTs(text string) []string {
+	var tokens []string
+	seen := make(map[string]bool)
+	for _, token := range jwtPattern.FindAllString(text, -1) {
+		if !seen[token] {
+			seen[token] = true
+			tokens = append(tokens, token)
+		}
+	}
+	return tokens
+}
+
+// decodeJWT decodes header and claims of a JWT without verifying its signature
+func decodeJWT(token string) (*JWTInfo, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return nil, fmt.Errorf("token has %d parts, expected 3", len(parts))
+	}
+	info := &JWTInfo{Token: token}
+	if err := decodeJWTSegment(parts[0], &info.Header); err != nil {
+		return nil, fmt.Errorf("invalid header: %v", err)
+	}
+	if err := decodeJWTSegment(parts[1], &info.Claims); err != nil {
+		return nil, fmt.Errorf("invalid claims: %v", err)
+	}
+	return info, nil
+}
+
+func decodeJWTSegment(segment string, target *map[string]any) error {
+	data, err := base64.RawURLEncoding.DecodeString(strings.TrimRight(segment, "="))
+	if err != nil {
+		return err
+	}
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	return decoder.Decode(target)
+}
+
+// claimTime returns a NumericDate claim (seconds since epoch) as time
+func (info *JWTInfo) claimTime(name string) (time.Time, bool) {
+	number, ok := info.Claims[name].(json.Number)
+	if !ok {
+		return time.Time{}, false
+	}
+	seconds, err := number.Float64()
+	if err != nil {
+		return time.Time{}, false
+	}
+	return time.Unix(int64(seconds), 0), true
+}
+
+// Format renders header, claims and the validity period relative to now
+func (info *JWTInfo) Format(now time.Time) string {
+	var builder strings.Builder
+	header, _ := json.MarshalIndent(info.Header, "", "  ")
+	claims, _ := json.MarshalIndent(info.Claims, "", "  ")
+	builder.WriteString("Header:\n")
+	builder.Write(header)
+	builder.WriteString("\nClaims:\n")
+	builder.Write(claims)
+	builder.WriteString("\n")
+
+	if issued, ok := info.claimTime("iat"); ok {
+		fmt.Fprintf(&builder, "Issued:  %s (%s)\n", issued.Format(time.RFC3339), relativeTime(issued, now))
+	}
+	if notBefore, ok := info.claimTime("nbf"); ok {
+		fmt.Fprintf(&builder, "Valid from: %s (%s)\n", notBefore.Format(time.RFC3339), relativeTime(notBefore, now))
+	}
+	if expiry, ok := info.claimTime("exp"); ok {
+		state := "valid"
+		if !now.Before(expiry) {
+			state = "EXPIRED"
+		}
+		fmt.Fprintf(&builder, "Expires: %s (%s, %s)\n", expiry.Format(time.RFC3339), relativeTime(expiry, now), state)
+	}
+	return strings.ReplaceAll(builder.String(), "\n", "\r\n")
+}
+
+// relativeTime describes t relative to now, e.g. "in 5m0s" or "2h0m0s ago"
+func relativeTime(t, now time.Time) string {
+	d := t.Sub(now).Round(time.Second)
+	if d >= 0 {
+		return "in " + d.String()
+	}
+	return (-d).String() + " ago"
+}
+
+// formatJWTs decodes every JWT found in the response headers and body
+func (responseData *ResponseData) formatJWTs(now time.Time) string {
+	var text strings.Builder
+	for _, header := range responseData.Headers {
+		text.WriteString(header.Value)
+		text.WriteString("\n")
+	}
+	text.WriteString(responseData.Body())
+
+	var sections []string
+	for _, token := range findJWTs(text.String()) {
+		info, err := decodeJWT(token)
+		if err != nil {
+			continue
+		}
+		sections = append(sections, info.Format(now))
+	}
+	return strings.Join(sections, "\r\n")
+}
+
+// JWTConfig configures a self-signed token that is sent as bearer token
+type JWTConfig struct {
+	Algorithm   string `json:"algorithm,omitempty"`   // HS256, RS256 or ES256; derived from the key if empty
+	Secret      string `json:"secret,omitempty"`      // Shared secret for HS256
+	KeyFile     string `json:"keyFile,omitempty"`     // PEM private key for RS256 or ES256
+	Claims      string `json:"claims,omitempty"`      // JSON object with the claims to include
+	LifetimeSec int64  `json:"lifetimeSec,omitempty"` // Token lifetime, 300 seconds if not set
+}
+
+// mint creates and signs a new token with fresh iat and exp claims
+func (config *JWTConfig) mint(now time.Time) (string, error) {
+	claims := make(map[string]any)
+	if strings.TrimSpace(config.Claims) != "" {
+		decoder := json.NewDecoder(strings.NewReader(config.Claims))
+		decoder.UseNumber()
+		if err := decoder.Decode(&claims); err != nil {
+			return "", fmt.Errorf("invalid JWT claims: %v", err)
+		}
+	}
+	lifetime := config.LifetimeSec
+	if lifetime <= 0 {
+		lifetime = 300
+	}
+	if _, ok := claims["iat"]; !ok {
+		claims["iat"] = now.Unix()
+	}
+	if _, ok := claims["exp"]; !ok {
+		claims["exp"] = now.Unix() + lifetime
+	}
+
+	var key crypto.Signer
+	algorithm := config.Algorithm
+	if strings.TrimSpace(config.KeyFile) != "" {
+		var err error
+		if key, err = loadPrivateKey(strings.TrimSpace(config.KeyFile)); err != nil {
+			return "", err
+		}
+		if algorithm == "" {
+			switch key.(type) {
+			case *rsa.PrivateKey:
+				algorithm = "RS256"
+			case *ecdsa.PrivateKey:
+				algorithm = "ES256"
+			}
+		}
+	} else if algorithm == "" {
+		algorithm = "HS256"
+	}
+
+	header, err := json.Marshal(map[string]string{"alg": algorithm, "typ": "JWT"})
+	if err != nil {
+		return "", err
+	}
+	payload, err := json.Marshal(claims)
+	if err != nil {
+		return "", err
+	}
+	signingInput := base64.RawURLEncoding.EncodeToString(header) + "." + base64.RawURLEncoding.EncodeToString(payload)
+	digest := sha256.Sum256([]byte(signingInput))
+
+	var signature []byte
+	switch algorithm {
+	case "HS256":
+		if config.Secret == "" {
+			return "", fmt.Errorf("HS256 requires a shared secret")
+		}
+		mac := hmac.New(sha256.New, []byte(config.Secret))
+		mac.Write([]byte(signingInput))
+		signature = mac.Sum(nil)
+	case "RS256":
+		rsaKey, ok := key.(*rsa.PrivateKey)
+		if !ok {
+			return "", fmt.Errorf("RS256 requires an RSA private key")
+		}
+		if signature, err = rsa.SignPKCS1v15(rand.Reader, rsaKey, crypto.SHA256, digest[:]); err != nil {
+			return "", err
+		}
+	case "ES256":
+		ecKey, ok := key.(*ecdsa.PrivateKey)
+		if !ok || ecKey.Curve != elliptic.P256() {
+			return "", fmt.Errorf("ES256 requires a P-256 EC private key")
+		}
+		r, s, err := ecdsa.Sign(rand.Reader, ecKey, digest[:])
+		if err != nil {
+			return "", err
+		}
+		// JWS uses the fixed-size r||s encoding instead of ASN.1
+		signature = make([]byte, 64)
+		r.FillBytes(signature[:32])
+		s.FillBytes(signature[32:])
+	default:
+		return "", fmt.Errorf("unsupported JWT algorithm %q", algorithm)
+	}
+	return signingInput + "." + base64.RawURLEncoding.EncodeToString(signature), nil
+}
+
+// loadPrivateKey reads a PKCS#1, PKCS#8 or SEC 1 private key from a PEM file
+func loadPrivateKey(path string) (crypto.Signer, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read private key: %v", err)
+	}
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, fmt.Errorf("no PEM data found in %s", path)
+	}
+	switch block.Type {
+	case "RSA PRIVATE KEY":
+		return x509.ParsePKCS1PrivateKey(block.Bytes)
+	case "EC PRIVATE KEY":
+		return x509.ParseECPrivateKey(block.Bytes)
+	}
+	key, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse private key: %v", err)
+	}
+	signer, ok := key.(crypto.Signer)
+	if !ok {
+		return nil, fmt.Errorf("unsupported private key type %T", key)
+	}
+	return signer, nil
+}
@@ -0,0 +1,89 @@
+package main
+
+import (
+	"bytes"
+	"sync"
+	"time"
+)
+
+// maxLiveText limits the text shown while a response streams or a WebSocket connection
+// is open; older lines are dropped from the view, the final response keeps them
+const maxLiveText = 1 << 20
+
+// liveUpdateInterval is the minimum time between updates of the live view
+const liveUpdateInterval = 100 * time.Millisecond
+
+// liveText batches lines arriving on background goroutines into updates of the view on
+// the UI thread, so that long streams neither redraw the whole text for every line
+// nor grow the view without bound
+type liveText struct {
+	mu        sync.Mutex
+	pending   []byte // Lines not shown yet
+	scheduled bool   // An update of the view is scheduled
+	shown     int    // Length of the text in the view, -1 if it has to be replaced
+	dropped   bool   // Lines were dropped since the view was last replaced
+	handed    bool   // Lines were taken for the view before
+	finished  bool   // The response was added, late updates are ignored
+}
+
+// add queues a line and reports whether the caller has to schedule an update,
+// which calls take after liveUpdateInterval
+func (live *liveText) add(line string) bool {
+	live.mu.Lock()
+	defer live.mu.Unlock()
+	live.pending = append(append(live.pending, line...), "\r\n"...)
+	if len(live.pending) > maxLiveText {
+		// Keep the newer half, starting at a line
+		cut := len(live.pending) - maxLiveText/2
+		if next := bytes.Index(live.pending[cut:], []byte("\r\n")); next >= 0 {
+			cut += next + 2
+		}
+		live.pending = append([]byte(nil), live.pending[cut:]...)
+		live.dropped = true
+		live.shown = -1
+	}
+	if live.scheduled {
+		return false
+	}
+	live.scheduled = true
+	return true
+}
+
+// take returns the queued lines for the view, empty once finished. With replace set, the text replaces the view
+// instead of being appended, e.g. because the view would exceed maxLiveText.
+func (live *liveText) take() (text string, replace bool) {
+	live.mu.Lock()
+	defer live.mu.Unlock()
+	live.scheduled = false
+	if live.finished {
+		return "", false
+	}
+	text = string(live.pending)
+	live.pending = live.pending[:0]
+	earlier := live.dropped || live.handed
+	live.handed = live.handed || text != ""
+	if live.shown < 0 || live.shown+len(text) > maxLiveText {
+		if earlier {
+			text = "… earlier lines are not shown\r\n" + text
+		}
+		live.shown, live.dropped = len(text), false
+		return text, true
+	}
+	live.shown += len(text)
+	return text, false
+}
+
+// detach tells that the view shows something else, e.g. another tab; the next update replaces it
+func (live *liveText) detach() {
+	live.mu.Lock()
+	defer live.mu.Unlock()
+	live.shown = -1
+}
+
+// finish ends the live view when the response is added
+func (live *liveText) finish() {
+	live.mu.Lock()
+	defer live.mu.Unlock()
+	live.finished = true
+	live.pending = nil
+}
@@ -0,0 +1,117 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"hoermi.com/rest-test/win32"
+)
+
+// reportJob produces a report in the background and calls progress with how far it got.
+// The returned function is called on the UI thread and returns the text of the report.
+type reportJob func(ctx context.Context, progress func(status string)) func() string
+
+type reportPanelGroup struct {
+	*win32.ControllerGroup
+	statusLabel *win32.Control
+	stopBtn     *win32.ButtonControl
+	saveBtn     *win32.ButtonControl
+	reportText  *win32.Control
+
+	content        *ReportTabContent
+	controlFactory win32.ControlFactory
+}
+
+func (r *reportPanelGroup) Resize(tabHeight, width, height int32) {
+	y := tabHeight + layoutPadding
+	saveX := width - layoutPadding - layoutButtonWidth
+	stopX := saveX - layoutPadding - layoutButtonWidth
+	r.statusLabel.MoveWindow(layoutPadding, y+3, max(stopX-layoutPadding*2, layoutColumnWidth), layoutLabelHeight)
+	r.stopBtn.MoveWindow(stopX, y, layoutButtonWidth, layoutInputHeight)
+	r.saveBtn.MoveWindow(saveX, y, layoutButtonWidth, layoutInputHeight)
+	y += layoutInputHeight + layoutPadding
+	r.reportText.MoveWindow(layoutPadding, y, width-layoutPadding*2, max(height-y-layoutPadding, layoutListHeight))
+}
+
+func (r *reportPanelGroup) SaveState() {}
+
+func (r *reportPanelGroup) SetState(data any) {
+	if content, ok := data.(*ReportTabContent); ok {
+		r.content = content
+		r.statusLabel.SetText(content.Status)
+		r.reportText.SetText(content.Text)
+	}
+}
+
+// start runs the job producing the report of the content; Stop cancels it
+func (r *reportPanelGroup) start(content *ReportTabContent, job reportJob) {
+	ctx, cancel := context.WithCancel(context.Background())
+	content.cancel = cancel
+	// The panel is shared by all report tabs, only the shown one is updated
+	update := func() {
+		if r.content == content {
+			r.SetState(content)
+		}
+	}
+	go func() {
+		finish := job(ctx, func(status string) {
+			r.controlFactory.PostUICallback(func() {
+				if content.cancel != nil {
+					content.Status = status
+					update()
+				}
+			})
+		})
+		r.controlFactory.PostUICallback(func() {
+			content.Text = finish()
+			content.Status = "✅ Finished"
+			if ctx.Err() != nil {
+				content.Status = "⏹ Stopped, the report covers the part done until then"
+			}
+			content.cancel = nil
+			cancel()
+			update()
+		})
+	}()
+}
+
+// save writes the finished report to a file
+func (r *reportPanelGroup) save() {
+	if r.content == nil {
+		return
+	}
+	if r.content.cancel != nil {
+		r.statusLabel.SetText("The report is not finished yet")
+		return
+	}
+	path, ok := r.controlFactory.SaveFileDialog("Save Report", "Text Files (*.txt)|*.txt|All Files (*.*)|*.*|", "txt", r.content.FileName)
+	if !ok {
+		return
+	}
+	if err := os.WriteFile(path, []byte(r.content.Text), 0644); err != nil {
+		r.controlFactory.MessageBox("Error", fmt.Sprintf("Error saving report: %v", err))
+		return
+	}
+	r.statusLabel.SetText("💾 Saved report to " + filepath.Base(path))
+}
+
+func createReportPanel(factory win32.ControlFactory) *reportPanelGroup {
+	group := &reportPanelGroup{
+		statusLabel:    factory.CreateLabel(""),
+		reportText:     factory.CreateCodeEdit(true),
+		controlFactory: factory,
+	}
+	group.stopBtn = factory.CreateButton("Stop", func() {
+		if group.content != nil && group.content.cancel != nil {
+			group.content.cancel()
+			group.statusLabel.SetText("⏹ Stopping...")
+		}
+	})
+	group.saveBtn = factory.CreateButton("Save Report", group.save)
+	group.ControllerGroup = win32.NewControllerGroup(
+		group.statusLabel, group.stopBtn, group.saveBtn, group.reportText,
+	)
+	return group
+}
@@ -1,7 +1,14 @@
 package main
 
 import (
+	"context"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
 	"strings"
 	"time"
 
@@ -10,36 +17,86 @@ import (
 
 var httpMethods = []string{"GET", "POST", "PUT", "PATCH", "DELETE", "HEAD", "OPTIONS"}
 
+// methodChoices are the entries of the method combo; WebSocket requests are chosen like a method
+var methodChoices = append(slices.Clone(httpMethods), webSocketMethod)
+
 type requestPanelGroup struct {
 	*win32.ControllerGroup
-	nameLabel        *win32.Control
-	nameInput        *win32.Control
-	methodCombo      *win32.ComboBoxControl
-	envCombo         *win32.ComboBoxControl
-	urlInput         *win32.Control
-	headersInput     *win32.Control
-	queryInput       *win32.Control
-	bodyInput        *win32.Control
-	responseTabCtrl  *win32.TabControlControl
-	responseBody     *win32.Control
-	responseHeaders  *win32.Control
-	responseInfo     *win32.Control
-	statusLabel      *win32.Control
-	sendBtn          *win32.ButtonControl
-	clearResponseBtn *win32.ButtonControl
-	manageEnvBtn     *win32.ButtonControl
-	appendBtn        *win32.ButtonControl
-	methodLabel      *win32.Control
-	envLabel         *win32.Control
-	urlLabel         *win32.Control
-	headersLabel     *win32.Control
-	queryLabel       *win32.Control
-	bodyLabel        *win32.Control
-	responseLabel    *win32.Control
+	nameLabel         *win32.Control
+	nameInput         *win32.Control
+	methodCombo       *win32.ComboBoxControl
+	envCombo          *win32.ComboBoxControl
+	urlInput          *win32.Control
+	headersInput      *win32.Control
+	queryInput        *win32.Control
+	queryEncCombo     *win32.ComboBoxControl
+	bodyInput         *win32.Control
+	bodyModeCombo     *win32.ComboBoxControl
+	bodyFileBtn       *win32.ButtonControl
+	chunkedChk        *win32.CheckBoxControl
+	graphQLVarsInput  *win32.Control
+	graphQLOpLabel    *win32.Control
+	graphQLOpInput    *win32.Control
+	introspectBtn     *win32.ButtonControl
+	wsMessageInput    *win32.Control
+	protoLabel        *win32.Control
+	protoSchemaInput  *win32.Control
+	protoSchemaBtn    *win32.ButtonControl
+	protoMessageInput *win32.Control
+	wsSendTextBtn     *win32.ButtonControl
+	wsSendBinaryBtn   *win32.ButtonControl
+	wsCloseBtn        *win32.ButtonControl
+	authCombo         *win32.ComboBoxControl
+	authUserInput     *win32.Control
+	authPassInput     *win32.Control
+	authClaimsInput   *win32.Control
+	snapshotLabel     *win32.Control
+	snapshotChk       *win32.CheckBoxControl
+	snapshotHdrLabel  *win32.Control
+	snapshotHdrInput  *win32.Control
+	snapshotIgnLabel  *win32.Control
+	snapshotIgnInput  *win32.Control
+	responseTabCtrl   *win32.TabControlControl
+	responseBody      *win32.Control
+	responseHeaders   *win32.Control
+	responseJWT       *win32.Control
+	responseInfo      *win32.Control
+	filterInput       *win32.Control
+	filterBtn         *win32.ButtonControl
+	diffBtn           *win32.ButtonControl
+	statusLabel       *win32.Control
+	sendBtn           *win32.ButtonControl
+	clearResponseBtn  *win32.ButtonControl
+	manageEnvBtn      *win32.ButtonControl
+	appendBtn         *win32.ButtonControl
+	freshConnChk      *win32.CheckBoxControl
+	streamingChk      *win32.CheckBoxControl
+	charsetCombo      *win32.ComboBoxControl
+	formatterCombo    *win32.ComboBoxControl
+	stopBtn           *win32.ButtonControl
+	saveBodyBtn       *win32.ButtonControl
+	methodLabel       *win32.Control
+	envLabel          *win32.Control
+	urlLabel          *win32.Control
+	headersLabel      *win32.Control
+	queryLabel        *win32.Control
+	bodyLabel         *win32.Control
+	authLabel         *win32.Control
+	authUserLabel     *win32.Control
+	authPassLabel     *win32.Control
+	authClaimsLabel   *win32.Control
+	responseLabel     *win32.Control
 
 	content        *RequestTabContent
+	shownBodyMode  BodyMode    // Body mode the body editor content was written for
+	shownKind      RequestKind // Request kind the body editor content was written for
+	bodyY          int32       // Body editor area, split for the GraphQL variables
+	bodyWidth      int32
+	bodyHeight     int32
 	tabController  TabController
 	controlFactory win32.ControlFactory
+	shownDiff      string    // Diff shown in place of the response body, empty if the body is shown
+	shownLive      *liveText // Live view shown in the response body, nil if none
 }
 
 func (r *requestPanelGroup) Resize(tabHeight, width, height int32) {
@@ -47,7 +104,7 @@ func (r *requestPanelGroup) Resize(tabHeight, width, height int32) {
 	bodyHeightRatio := 0.15
 
 	// Calculate available height (excluding tab bar and padding)
-	availableHeight := height - tabHeight - (layoutPadding-layoutLabelHeight)*6 - layoutInputHeight
+	availableHeight := height - tabHeight - (layoutPadding-layoutLabelHeight)*6 - layoutInputHeight*3 - layoutPadding*2
 
 	// Calculate panel heights based on ratios
 	minParamsHeight := int32(60)
@@ -80,6 +137,14 @@ func (r *requestPanelGroup) Resize(tabHeight, width, height int32) {
 	} else {
 		r.appendBtn.Hide()
 	}
+	freshConnWidth := int32(220)
+	r.freshConnChk.MoveWindow(width-layoutPadding-freshConnWidth, y, freshConnWidth, layoutInputHeight)
+	streamingWidth := int32(160)
+	r.streamingChk.MoveWindow(width-layoutPadding*2-freshConnWidth-streamingWidth, y, streamingWidth, layoutInputHeight)
+	charsetWidth := int32(140)
+	r.charsetCombo.MoveWindow(width-layoutPadding*3-freshConnWidth-streamingWidth-charsetWidth, y, charsetWidth, 200)
+	formatterWidth := int32(160)
+	r.formatterCombo.MoveWindow(width-layoutPadding*4-freshConnWidth-streamingWidth-charsetWidth-formatterWidth, y, formatterWidth, 200)
 
 	y += layoutInputHeight + layoutPadding
 	// Position method label and combo
@@ -102,12 +167,49 @@ func (r *requestPanelGroup) Resize(tabHeight, width, height int32) {
 	r.sendBtn.MoveWindow(width-layoutPadding-btnWidth*2-layoutPadding, y, btnWidth, layoutInputHeight)
 	r.clearResponseBtn.MoveWindow(width-layoutPadding-btnWidth-layoutPadding, y, btnWidth, layoutInputHeight)
 
+	// === Authentication Row ===
+	y += layoutInputHeight + layoutPadding
+	authComboWidth := int32(100)
+	authInputWidth := int32(160)
+	authLabelWidth := int32(110)
+	r.authLabel.MoveWindow(layoutPadding, y+3, methodLabelWidth, layoutLabelHeight)
+	authX := layoutPadding + methodLabelWidth + layoutPadding
+	r.authCombo.MoveWindow(authX, y, authComboWidth, 200)
+	authX += authComboWidth + layoutPadding
+	r.authUserLabel.MoveWindow(authX, y+3, authLabelWidth, layoutLabelHeight)
+	authX += authLabelWidth + layoutPadding
+	r.authUserInput.MoveWindow(authX, y, authInputWidth, layoutInputHeight)
+	authX += authInputWidth + layoutPadding
+	r.authPassLabel.MoveWindow(authX, y+3, authLabelWidth, layoutLabelHeight)
+	authX += authLabelWidth + layoutPadding
+	r.authPassInput.MoveWindow(authX, y, authInputWidth, layoutInputHeight)
+	authX += authInputWidth + layoutPadding
+	r.authClaimsLabel.MoveWindow(authX, y+3, methodLabelWidth+30, layoutLabelHeight)
+	authX += methodLabelWidth + 30 + layoutPadding
+	r.authClaimsInput.MoveWindow(authX, y, max(width-layoutPadding-authX, 100), layoutInputHeight)
+
+	// === Snapshot Row ===
+	y += layoutInputHeight + layoutPadding
+	r.snapshotLabel.MoveWindow(layoutPadding, y+3, methodLabelWidth, layoutLabelHeight)
+	snapshotX := layoutPadding + methodLabelWidth + layoutPadding
+	r.snapshotChk.MoveWindow(snapshotX, y, authComboWidth, layoutInputHeight)
+	snapshotX += authComboWidth + layoutPadding
+	r.snapshotHdrLabel.MoveWindow(snapshotX, y+3, authLabelWidth, layoutLabelHeight)
+	snapshotX += authLabelWidth + layoutPadding
+	r.snapshotHdrInput.MoveWindow(snapshotX, y, authInputWidth, layoutInputHeight)
+	snapshotX += authInputWidth + layoutPadding
+	r.snapshotIgnLabel.MoveWindow(snapshotX, y+3, authLabelWidth*2, layoutLabelHeight)
+	snapshotX += authLabelWidth*2 + layoutPadding
+	r.snapshotIgnInput.MoveWindow(snapshotX, y, max(width-layoutPadding-snapshotX, 100), layoutInputHeight)
+
 	// === Query Parameters & Headers Section ===
 	y += layoutInputHeight + layoutPadding
 
 	// Position section labels
 	halfWidth := (availableWidth - layoutPadding) / 2
-	r.queryLabel.MoveWindow(layoutPadding, y, 300, layoutLabelHeight)
+	queryEncWidth := int32(140)
+	r.queryLabel.MoveWindow(layoutPadding, y, min(300, halfWidth-queryEncWidth-layoutPadding), layoutLabelHeight)
+	r.queryEncCombo.MoveWindow(layoutPadding+halfWidth-queryEncWidth, y-3, queryEncWidth, 200)
 	r.headersLabel.MoveWindow(layoutPadding+halfWidth+layoutPadding, y, 300, layoutLabelHeight)
 
 	y += layoutLabelHeight + layoutPadding
@@ -116,14 +218,40 @@ func (r *requestPanelGroup) Resize(tabHeight, width, height int32) {
 
 	// === Body Section ===
 	y += paramsHeight + layoutPadding
-	r.bodyLabel.MoveWindow(layoutPadding, y, 150, layoutLabelHeight)
+	bodyLabelWidth := int32(240)
+	r.bodyLabel.MoveWindow(layoutPadding, y, bodyLabelWidth, layoutLabelHeight)
+	r.bodyModeCombo.MoveWindow(layoutPadding+bodyLabelWidth+layoutPadding, y-3, 180, 200)
+	r.bodyFileBtn.MoveWindow(layoutPadding+bodyLabelWidth+180+layoutPadding*2, y-3, btnWidth, layoutInputHeight)
+	r.chunkedChk.MoveWindow(layoutPadding+bodyLabelWidth+180+btnWidth+layoutPadding*3, y-3, 200, layoutInputHeight)
+	graphQLX := layoutPadding + bodyLabelWidth + 180 + btnWidth + 200 + layoutPadding*4
+	r.graphQLOpLabel.MoveWindow(graphQLX, y, 70, layoutLabelHeight)
+	r.graphQLOpInput.MoveWindow(graphQLX+70+layoutPadding, y-3, 160, layoutInputHeight)
+	r.introspectBtn.MoveWindow(graphQLX+70+160+layoutPadding*2, y-3, btnWidth, layoutInputHeight)
 	y += layoutLabelHeight + layoutPadding
-	r.bodyInput.MoveWindow(layoutPadding, y, availableWidth, bodyHeight)
+	r.bodyY, r.bodyWidth, r.bodyHeight = y, availableWidth, bodyHeight
+	r.layoutBody()
 
 	// === Response Section with TabControl ===
 	y += bodyHeight + layoutPadding
 	r.responseLabel.MoveWindow(layoutPadding, y, 80, layoutLabelHeight)
 	r.statusLabel.MoveWindow(layoutPadding+90, y, 400, layoutLabelHeight)
+	r.stopBtn.MoveWindow(layoutPadding+500, y-3, btnWidth, layoutInputHeight)
+	r.saveBodyBtn.MoveWindow(layoutPadding+500+btnWidth+layoutPadding, y-3, btnWidth, layoutInputHeight)
+	// Manual messages of an open WebSocket connection
+	wsX := layoutPadding + 500 + (btnWidth+layoutPadding)*2
+	wsInputWidth := max(availableWidth-500-btnWidth*5-layoutPadding*5, 100)
+	r.wsMessageInput.MoveWindow(wsX, y-3, wsInputWidth, layoutInputHeight)
+	wsX += wsInputWidth + layoutPadding
+	r.wsSendTextBtn.MoveWindow(wsX, y-3, btnWidth, layoutInputHeight)
+	r.wsSendBinaryBtn.MoveWindow(wsX+btnWidth+layoutPadding, y-3, btnWidth, layoutInputHeight)
+	r.wsCloseBtn.MoveWindow(wsX+btnWidth*2+layoutPadding*2, y-3, btnWidth, layoutInputHeight)
+	// Protobuf schema and message type of HTTP responses, in place of the WebSocket controls
+	protoX := layoutPadding + 500 + (btnWidth+layoutPadding)*2
+	r.protoLabel.MoveWindow(protoX, y, 130, layoutLabelHeight)
+	protoX += 130 + layoutPadding
+	r.protoSchemaInput.MoveWindow(protoX, y-3, 200, layoutInputHeight)
+	r.protoSchemaBtn.MoveWindow(protoX+200+layoutPadding/2, y-3, 30, layoutInputHeight)
+	r.protoMessageInput.MoveWindow(protoX+230+layoutPadding, y-3, 180, layoutInputHeight)
 	y += layoutLabelHeight + layoutPadding
 
 	// TabControl for multiple responses
@@ -139,15 +267,30 @@ func (r *requestPanelGroup) Resize(tabHeight, width, height int32) {
 	remainingHeight := contentHeight - infoHeight - layoutPadding
 	bodyHeadersHeight := remainingHeight / 2
 
-	r.responseInfo.MoveWindow(layoutPadding*2, contentY, availableWidth-layoutPadding*2, infoHeight)
+	// Filter expression of the shown response at the right of the info line
+	filterWidth := int32(300)
+	infoWidth := max(availableWidth-layoutPadding*5-filterWidth-btnWidth*2, 100)
+	r.responseInfo.MoveWindow(layoutPadding*2, contentY, infoWidth, infoHeight)
+	r.filterInput.MoveWindow(layoutPadding*3+infoWidth, contentY-3, filterWidth, layoutInputHeight)
+	r.filterBtn.MoveWindow(layoutPadding*4+infoWidth+filterWidth, contentY-3, btnWidth, layoutInputHeight)
+	r.diffBtn.MoveWindow(layoutPadding*5+infoWidth+filterWidth+btnWidth, contentY-3, btnWidth, layoutInputHeight)
 	r.responseBody.MoveWindow(layoutPadding*2, contentY+infoHeight+layoutPadding, availableWidth-layoutPadding*2, bodyHeadersHeight)
-	r.responseHeaders.MoveWindow(layoutPadding*2, contentY+infoHeight+layoutPadding+bodyHeadersHeight+layoutPadding, availableWidth-layoutPadding*2, bodyHeadersHeight)
+	// Headers and decoded JWTs share the lower half side by side
+	headersWidth := (availableWidth - layoutPadding*3) / 2
+	r.responseHeaders.MoveWindow(layoutPadding*2, contentY+infoHeight+layoutPadding+bodyHeadersHeight+layoutPadding, headersWidth, bodyHeadersHeight)
+	r.responseJWT.MoveWindow(layoutPadding*2+headersWidth+layoutPadding, contentY+infoHeight+layoutPadding+bodyHeadersHeight+layoutPadding, headersWidth, bodyHeadersHeight)
 }
 
 func (r *requestPanelGroup) SaveState() {
 	req := r.content.BoundRequest
 	req.Name = r.nameInput.GetText()
-	req.Method = r.methodCombo.GetText()
+	if method := r.methodCombo.GetText(); method == webSocketMethod {
+		req.Kind = KindWebSocket
+		req.Method = "GET"
+	} else {
+		req.Kind = KindHTTP
+		req.Method = method
+	}
 
 	// Save the path if it's editable (Pending state)
 	if r.content.Pending {
@@ -168,20 +311,92 @@ func (r *requestPanelGroup) SaveState() {
 	}
 	req.Host = baseURL
 
-	req.Body = r.bodyInput.GetText()
+	r.content.FreshConn = r.freshConnChk.IsChecked()
+	req.Streaming = r.streamingChk.IsChecked()
+	if charsetIndex := r.charsetCombo.GetCurSel(); charsetIndex >= 0 && charsetIndex < len(charsets) {
+		req.Charset = charsets[charsetIndex]
+	}
+	if formatterIndex := r.formatterCombo.GetCurSel(); formatterIndex >= 0 && formatterIndex < len(formatterNames) {
+		req.Formatter = formatterNames[formatterIndex]
+	}
+	req.Protobuf = nil
+	if schemaFile, message := strings.TrimSpace(r.protoSchemaInput.GetText()), strings.TrimSpace(r.protoMessageInput.GetText()); schemaFile != "" || message != "" {
+		req.Protobuf = &ProtobufConfig{SchemaFile: schemaFile, Message: message}
+	}
+	req.Snapshot = nil
+	if r.snapshotChk.IsChecked() {
+		req.Snapshot = &SnapshotConfig{
+			Headers: splitSnapshotRules(r.snapshotHdrInput.GetText(), ","),
+			Ignore:  splitSnapshotRules(r.snapshotIgnInput.GetText(), ";"),
+		}
+	}
+
+	// The editor holds the WebSocket script, or the body in the format of the mode it was filled for
+	switch {
+	case r.shownKind == KindWebSocket:
+		req.WebSocketSteps = ParseWebSocketSteps(r.bodyInput.GetText())
+	case r.shownBodyMode == BodyForm, r.shownBodyMode == BodyMultipart:
+		req.FormFields = ParseFormFields(r.bodyInput.GetText())
+	case r.shownBodyMode == BodyFile:
+		req.BodyFile = strings.TrimSpace(r.bodyInput.GetText())
+	case r.shownBodyMode == BodyGraphQL:
+		req.GraphQL = &GraphQLBody{
+			Query:         r.bodyInput.GetText(),
+			Variables:     r.graphQLVarsInput.GetText(),
+			OperationName: strings.TrimSpace(r.graphQLOpInput.GetText()),
+		}
+	default:
+		req.Body = r.bodyInput.GetText()
+	}
+	req.Chunked = r.chunkedChk.IsChecked()
+	if modeIndex := r.bodyModeCombo.GetCurSel(); modeIndex >= 0 && modeIndex < len(bodyModes) {
+		req.BodyMode = bodyModes[modeIndex]
+	}
+	if req.BodyMode != r.shownBodyMode || req.Kind != r.shownKind {
+		r.showBody(req)
+	}
 	req.Headers = ParseParams(r.headersInput.GetText())
 	req.QueryParams = ParseParams(r.queryInput.GetText())
+	if encIndex := r.queryEncCombo.GetCurSel(); encIndex >= 0 && encIndex < len(queryEncodings) {
+		req.QueryEncoding = queryEncodings[encIndex]
+	}
+
+	authType := AuthNone
+	if authIndex := r.authCombo.GetCurSel(); authIndex >= 0 && authIndex < len(authTypes) {
+		authType = authTypes[authIndex]
+	}
+	if authType == AuthNone {
+		req.Auth = nil
+	} else {
+		if req.Auth == nil {
+			req.Auth = &AuthConfig{}
+		}
+		req.Auth.Type = authType
+		if authType == AuthJWT {
+			// For JWT the inputs hold the signing key file, the shared secret and the claims
+			if req.Auth.JWT == nil {
+				req.Auth.JWT = &JWTConfig{}
+			}
+			req.Auth.JWT.KeyFile = r.authUserInput.GetText()
+			req.Auth.JWT.Secret = r.authPassInput.GetText()
+			req.Auth.JWT.Claims = r.authClaimsInput.GetText()
+		} else {
+			req.Auth.Username = r.authUserInput.GetText()
+			req.Auth.Password = r.authPassInput.GetText()
+		}
+	}
 	// Responses are managed separately, no need to save here
 }
 
 func (r *requestPanelGroup) SetState(data any) {
 	if content, ok := data.(*RequestTabContent); ok {
 		r.content = content
+		r.shownLive = nil
 		req := content.BoundRequest
 
 		// Set method
-		for i, m := range httpMethods {
-			if m == req.Method {
+		for i, m := range methodChoices {
+			if m == req.methodLabel() {
 				r.methodCombo.SetCurSel(i)
 				break
 			}
@@ -219,6 +434,23 @@ func (r *requestPanelGroup) SetState(data any) {
 		}
 
 		r.nameInput.SetText(req.Name)
+		r.freshConnChk.SetChecked(content.FreshConn)
+		r.streamingChk.SetChecked(req.Streaming)
+		r.charsetCombo.SetCurSel(max(slices.Index(charsets, normalizeCharset(req.Charset)), 0))
+		r.formatterCombo.SetCurSel(max(slices.Index(formatterNames, req.Formatter), 0))
+		protobuf := req.Protobuf
+		if protobuf == nil {
+			protobuf = &ProtobufConfig{}
+		}
+		r.protoSchemaInput.SetText(protobuf.SchemaFile)
+		r.protoMessageInput.SetText(protobuf.Message)
+		r.snapshotChk.SetChecked(req.Snapshot != nil)
+		snapshot := req.Snapshot
+		if snapshot == nil {
+			snapshot = &SnapshotConfig{}
+		}
+		r.snapshotHdrInput.SetText(strings.Join(snapshot.Headers, ", "))
+		r.snapshotIgnInput.SetText(strings.Join(snapshot.Ignore, "; "))
 		r.urlInput.SetText(content.Path)
 		// Set URL input readonly state based on Pending flag
 		// If Pending is true, the path is editable; otherwise it's readonly
@@ -226,13 +458,116 @@ func (r *requestPanelGroup) SetState(data any) {
 
 		r.headersInput.SetText(req.Headers.Format())
 		r.queryInput.SetText(req.QueryParams.Format())
-		r.bodyInput.SetText(req.Body)
+		r.queryEncCombo.SetCurSel(0)
+		for i, encoding := range queryEncodings {
+			if encoding == req.QueryEncoding {
+				r.queryEncCombo.SetCurSel(i)
+			}
+		}
+		r.showBody(req)
+		for i, mode := range bodyModes {
+			if mode == req.BodyMode {
+				r.bodyModeCombo.SetCurSel(i)
+			}
+		}
+		r.chunkedChk.SetChecked(req.Chunked)
+
+		// Set authentication
+		r.authCombo.SetCurSel(0)
+		r.authUserInput.SetText("")
+		r.authPassInput.SetText("")
+		r.authClaimsInput.SetText("")
+		if req.Auth != nil {
+			for i, t := range authTypes {
+				if t == req.Auth.Type {
+					r.authCombo.SetCurSel(i)
+					break
+				}
+			}
+			if req.Auth.Type == AuthJWT && req.Auth.JWT != nil {
+				r.authUserInput.SetText(req.Auth.JWT.KeyFile)
+				r.authPassInput.SetText(req.Auth.JWT.Secret)
+				r.authClaimsInput.SetText(req.Auth.JWT.Claims)
+			} else {
+				r.authUserInput.SetText(req.Auth.Username)
+				r.authPassInput.SetText(req.Auth.Password)
+			}
+		}
 
 		// Update response tabs
 		r.updateResponseTabs()
 	}
 }
 
+// showBody fills the body editor for the body mode of the request
+func (r *requestPanelGroup) showBody(req *Request) {
+	r.shownBodyMode = req.BodyMode
+	r.shownKind = req.Kind
+	switch {
+	case req.Kind == KindWebSocket:
+		r.bodyLabel.SetText("Script (send, send-binary, expect, wait)")
+		r.bodyInput.SetText(FormatWebSocketSteps(req.WebSocketSteps))
+	case req.BodyMode == BodyForm, req.BodyMode == BodyMultipart:
+		r.bodyLabel.SetText("Form fields (name: value or name: @file)")
+		r.bodyInput.SetText(FormatFormFields(req.FormFields))
+	case req.BodyMode == BodyFile:
+		r.bodyLabel.SetText("File to upload (relative to the project)")
+		r.bodyInput.SetText(req.BodyFile)
+	case req.BodyMode == BodyGraphQL:
+		graphQL := req.GraphQL
+		if graphQL == nil {
+			graphQL = &GraphQLBody{}
+		}
+		r.bodyLabel.SetText("GraphQL query and variables (JSON)")
+		r.bodyInput.SetText(graphQL.Query)
+		r.graphQLVarsInput.SetText(graphQL.Variables)
+		r.graphQLOpInput.SetText(graphQL.OperationName)
+	default:
+		r.bodyLabel.SetText("Request Body")
+		r.bodyInput.SetText(req.Body)
+	}
+
+	// The GraphQL and WebSocket controls are only shown for their mode
+	for _, control := range []win32.Controller{r.graphQLVarsInput, r.graphQLOpLabel, r.graphQLOpInput, r.introspectBtn} {
+		if r.showsGraphQL() {
+			control.Show()
+		} else {
+			control.Hide()
+		}
+	}
+	for _, control := range []win32.Controller{r.wsMessageInput, r.wsSendTextBtn, r.wsSendBinaryBtn, r.wsCloseBtn} {
+		if req.Kind == KindWebSocket {
+			control.Show()
+		} else {
+			control.Hide()
+		}
+	}
+	for _, control := range []win32.Controller{r.protoLabel, r.protoSchemaInput, r.protoSchemaBtn, r.protoMessageInput} {
+		if req.Kind == KindWebSocket {
+			control.Hide()
+		} else {
+			control.Show()
+		}
+	}
+	r.layoutBody()
+}
+
+// layoutBody places the body editor, next to the variables editor in GraphQL mode
+func (r *requestPanelGroup) layoutBody() {
+	if !r.showsGraphQL() {
+		r.bodyInput.MoveWindow(layoutPadding, r.bodyY, r.bodyWidth, r.bodyHeight)
+		return
+	}
+	queryWidth := (r.bodyWidth - layoutPadding) * 2 / 3
+	r.bodyInput.MoveWindow(layoutPadding, r.bodyY, queryWidth, r.bodyHeight)
+	r.graphQLVarsInput.MoveWindow(layoutPadding+queryWidth+layoutPadding, r.bodyY, r.bodyWidth-queryWidth-layoutPadding, r.bodyHeight)
+}
+
+// showsGraphQL reports whether the body editor holds a GraphQL query
+func (r *requestPanelGroup) showsGraphQL() bool {
+	return r.shownKind == KindHTTP && r.shownBodyMode == BodyGraphQL
+}
+
 // updateResponseTabs rebuilds the response tabs from the content
 func (r *requestPanelGroup) updateResponseTabs() {
 	r.responseTabCtrl.DeleteAllItems()
@@ -241,6 +576,8 @@ func (r *requestPanelGroup) updateResponseTabs() {
 		r.responseInfo.SetText("No responses yet. Click 'Send' to make a request.")
 		r.responseBody.SetText("")
 		r.responseHeaders.SetText("")
+		r.responseJWT.SetText("")
+		r.filterInput.SetText("")
 		r.statusLabel.SetText("Ready")
 		return
 	}
@@ -248,6 +585,9 @@ func (r *requestPanelGroup) updateResponseTabs() {
 	// Add tab for each response (newest first)
 	for i, resp := range r.content.Responses {
 		tabName := fmt.Sprintf("#%d - %s", len(r.content.Responses)-i, resp.Status)
+		if len(resp.Failures) > 0 {
+			tabName += " ⚠"
+		}
 		r.responseTabCtrl.InsertItem(i, tabName, uintptr(i))
 	}
 
@@ -262,50 +602,418 @@ func (r *requestPanelGroup) displayResponse(index int) {
 		return
 	}
 
-	resp := r.content.Responses[index]
+	resp := &r.content.Responses[index]
+	r.shownDiff = ""
+	r.shownLive = nil
 
 	// Update info label
 	infoText := fmt.Sprintf("Duration: %v | Time: %s",
 		resp.Duration.Round(1000), // Round to microseconds
 		resp.Timestamp.Format("15:04:05"))
-	r.responseInfo.SetText(infoText)
+	if len(resp.RoundTrips) > 1 {
+		// Show every exchange, e.g. the Digest challenge followed by the authorized request
+		var trips []string
+		for _, trip := range resp.RoundTrips {
+			trips = append(trips, fmt.Sprintf("%s (%v)", trip.Status, trip.Duration.Round(1000)))
+		}
+		infoText += " | Round trips: " + strings.Join(trips, " → ")
+	}
+	if resp.StatusCode != 0 {
+		infoText += " | " + resp.Timing.String()
+		if resp.Events == nil {
+			infoText += " | Size: " + formatSize(resp.Size)
+			if resp.Encoding != "" {
+				infoText += " " + resp.Encoding
+			}
+			if charset := resp.Charset(); charset != "" {
+				infoText += " | Charset: " + charset
+			}
+			if resp.Truncated {
+				infoText += " (truncated in view)"
+			}
+		}
+	}
 	r.statusLabel.SetText(fmt.Sprintf("✅ %s", resp.Status))
+	if len(resp.Failures) > 0 {
+		// E.g. GraphQL errors delivered with a 200 response
+		infoText += fmt.Sprintf(" | ⚠ %d failure(s): %s", len(resp.Failures), strings.Join(resp.Failures, "; "))
+		r.statusLabel.SetText(fmt.Sprintf("⚠ %s with %d failure(s)", resp.Status, len(resp.Failures)))
+	}
+	r.responseInfo.SetText(infoText)
+
+	// Update body, narrowed by the filter of the response
+	r.filterInput.SetText(resp.Filter)
+	r.responseBody.SetText(resp.FilteredBody())
+
+	// Format headers for display, one line per value
+	r.responseHeaders.SetText(resp.Headers.Format())
+
+	// Decode JWTs found in the response
+	r.responseJWT.SetText(resp.formatJWTs(time.Now()))
+}
+
+// responseTitle names a response in diffs the way its tab does
+func (r *requestPanelGroup) responseTitle(index int) string {
+	resp := &r.content.Responses[index]
+	return fmt.Sprintf("#%d - %s (%s)", len(r.content.Responses)-index, resp.Status, resp.Timestamp.Format("15:04:05"))
+}
+
+// showDiffMenu offers to compare the shown response with any other response,
+// the older one of both is the base of the diff. A shown diff can be saved as text.
+func (r *requestPanelGroup) showDiffMenu() {
+	if r.content == nil || len(r.content.Responses) == 0 {
+		return
+	}
+	const menuIDSave = 1
+	const menuIDSnapshot = 2
+	const menuIDApprove = 3
+	const menuIDCompare = 1000
+	index := min(max(r.responseTabCtrl.GetCurSel(), 0), len(r.content.Responses)-1)
+	menu := r.controlFactory.CreatePopupMenu()
+	defer menu.Destroy()
+	for other := range r.content.Responses {
+		if other != index {
+			menu.AddItem(menuIDCompare+other, "Compare with "+r.responseTitle(other))
+		}
+	}
+	project, request := r.content.BoundProject, r.content.BoundRequest
+	if project != nil && r.content.Responses[index].StatusCode != 0 {
+		menu.AddSeparator()
+		menu.AddItem(menuIDSnapshot, "Compare with approved snapshot")
+		menu.AddItem(menuIDApprove, "Approve as snapshot")
+	}
+	if r.shownDiff != "" {
+		menu.AddSeparator()
+		menu.AddItem(menuIDSave, "Save Diff...")
+	}
+	selected := menu.Show()
+	switch {
+	case selected == menuIDSnapshot:
+		snapshot, err := project.loadSnapshot(request)
+		if err == nil && snapshot == nil {
+			err = errors.New("no snapshot was approved yet")
+		}
+		var diff ResponseDiff
+		if err == nil {
+			diff, err = project.compareSnapshot(request, snapshot, &r.content.Responses[index], r.content.Settings)
+		}
+		if err != nil {
+			r.controlFactory.MessageBox("Error", fmt.Sprintf("Error comparing with snapshot: %v", err))
+			return
+		}
+		r.shownDiff = diff.String()
+		r.responseBody.SetText(r.shownDiff)
+		if diff.Equal() {
+			r.statusLabel.SetText("✅ Snapshot matches")
+		} else {
+			r.statusLabel.SetText("⚠ Snapshot differs")
+		}
+	case selected == menuIDApprove:
+		if err := project.approveSnapshot(request, &r.content.Responses[index]); err != nil {
+			r.controlFactory.MessageBox("Error", fmt.Sprintf("Error approving snapshot: %v", err))
+			return
+		}
+		r.statusLabel.SetText("📸 Approved " + r.responseTitle(index) + " as snapshot")
+	case selected == menuIDSave:
+		path, ok := r.controlFactory.SaveFileDialog("Save Diff", "Diff Files (*.diff)|*.diff|Text Files (*.txt)|*.txt|All Files (*.*)|*.*|", "diff", "response.diff")
+		if !ok {
+			return
+		}
+		if err := os.WriteFile(path, []byte(r.shownDiff), 0644); err != nil {
+			r.controlFactory.MessageBox("Error", fmt.Sprintf("Error saving diff: %v", err))
+			return
+		}
+		r.statusLabel.SetText("💾 Saved diff to " + filepath.Base(path))
+	case selected >= menuIDCompare && selected-menuIDCompare < len(r.content.Responses):
+		// Responses are stored newest first
+		older, newer := max(index, selected-menuIDCompare), min(index, selected-menuIDCompare)
+		diff := diffResponses(&r.content.Responses[older], &r.content.Responses[newer], r.responseTitle(older), r.responseTitle(newer))
+		r.shownDiff = diff.String()
+		r.responseBody.SetText(r.shownDiff)
+		if diff.Equal() {
+			r.statusLabel.SetText("No differences")
+		} else {
+			r.statusLabel.SetText(fmt.Sprintf("Diff of #%d and #%d", len(r.content.Responses)-older, len(r.content.Responses)-newer))
+		}
+	}
+}
+
+// addResponse adds a response as newest to the content and shows it if the content is displayed
+func (r *requestPanelGroup) addResponse(content *RequestTabContent, responseData ResponseData) {
+	content.Responses = append([]ResponseData{responseData}, content.Responses...)
+	if r.content == content {
+		r.updateResponseTabs()
+	}
+	// Keep the response in the history of the request beyond the tab
+	if project := content.BoundProject; project != nil {
+		project.recordHistory(content.BoundRequest, content.Path, responseData, func(err error) {
+			if err == nil {
+				return
+			}
+			r.controlFactory.PostUICallback(func() {
+				if r.content == content {
+					r.statusLabel.SetText(fmt.Sprintf("⚠ %v", err))
+				}
+			})
+		})
+	}
+}
+
+// addErrorResponse adds a response describing a failed or cancelled send
+func (r *requestPanelGroup) addErrorResponse(content *RequestTabContent, err error) {
+	if errors.Is(err, ErrCancelled) {
+		r.addResponse(content, ResponseData{
+			Text:      "Request cancelled",
+			Headers:   Params{},
+			Status:    "Cancelled",
+			Timestamp: time.Now(),
+			Cancelled: true,
+		})
+		if r.content == content {
+			r.statusLabel.SetText("⏹ Cancelled")
+		}
+		return
+	}
+	r.addResponse(content, ResponseData{
+		Text:       fmt.Sprintf("Error sending request:\r\n%v", err),
+		Headers:    Params{},
+		StatusCode: 0,
+		Status:     "Error",
+		Duration:   0,
+		Timestamp:  time.Now(),
+	})
+	if r.content == content {
+		r.statusLabel.SetText("❌ Error")
+	}
+}
+
+// trackSend registers a running send of the content so that Stop cancels it.
+// The returned function must be called on the UI thread when the send ended.
+func (r *requestPanelGroup) trackSend(content *RequestTabContent, cancel context.CancelFunc) func() {
+	previous := content.cancel
+	content.cancel = func() {
+		if previous != nil {
+			previous()
+		}
+		cancel()
+	}
+	content.running++
+	return func() {
+		cancel()
+		content.running--
+		if content.running == 0 {
+			content.cancel = nil
+		}
+	}
+}
+
+// scheduleLiveUpdate shows the lines queued in live after liveUpdateInterval, if the
+// content is displayed then, along with the status if given
+func (r *requestPanelGroup) scheduleLiveUpdate(content *RequestTabContent, live *liveText, status string) {
+	time.AfterFunc(liveUpdateInterval, func() {
+		r.controlFactory.PostUICallback(func() {
+			if r.content != content || r.shownLive != live {
+				// The view shows something else, e.g. after switching tabs
+				live.detach()
+			}
+			text, replace := live.take()
+			if r.content != content {
+				return
+			}
+			if text == "" {
+				return
+			}
+			if status != "" {
+				r.statusLabel.SetText(status)
+			}
+			r.shownLive = live
+			if replace {
+				r.responseBody.SetText(text)
+			} else {
+				r.responseBody.AppendText(text)
+			}
+		})
+	})
+}
+
+// connectWebSocket opens the connection of a WebSocket request and runs its script.
+// The connection stays open for manual messages until it is closed; its transcript
+// is then added as response.
+func (r *requestPanelGroup) connectWebSocket() {
+	content := r.content
+	if content.WebSocket != nil {
+		r.statusLabel.SetText("⚠ Already connected")
+		return
+	}
+	request := content.BoundRequest
+	steps := request.WebSocketSteps
+	options := r.sendOptions()
+	factory := r.controlFactory
+	r.statusLabel.SetText("⏳ Connecting...")
+	r.responseBody.SetText("")
+	ctx, cancel := context.WithCancel(context.Background())
+	done := r.trackSend(content, cancel)
+
+	// Show the transcript while the connection is open and its tab is displayed
+	live := &liveText{}
+	go func() {
+		session, err := request.openWebSocket(ctx, options, func(entry WebSocketEntry) {
+			if live.add(entry.String()) {
+				r.scheduleLiveUpdate(content, live, "")
+			}
+		})
+		factory.PostUICallback(func() {
+			if err != nil {
+				done()
+				live.finish()
+				r.addErrorResponse(content, err)
+				return
+			}
+			content.WebSocket = session
+			if r.content == content {
+				r.statusLabel.SetText("🔌 Connected to " + session.URL)
+			}
+		})
+		if err != nil {
+			return
+		}
+
+		if err := session.RunScript(steps, time.Duration(options.TimeoutInMs)*time.Millisecond); err != nil {
+			factory.PostUICallback(func() {
+				if r.content == content {
+					r.statusLabel.SetText("⚠ " + err.Error())
+				}
+			})
+		}
+		<-session.Done()
+		factory.PostUICallback(func() {
+			done()
+			live.finish()
+			content.WebSocket = nil
+			r.addResponse(content, *session.ResponseData())
+		})
+	}()
+}
+
+// sendWebSocketMessage sends the message input over the open connection, as hex decoded binary message if binary is set
+func (r *requestPanelGroup) sendWebSocketMessage(binary bool) {
+	session := r.content.WebSocket
+	if session == nil {
+		r.statusLabel.SetText("❌ Not connected")
+		return
+	}
+	text := r.wsMessageInput.GetText()
+	var data []byte
+	if binary {
+		var err error
+		if data, err = hex.DecodeString(strings.ReplaceAll(text, " ", "")); err != nil {
+			r.statusLabel.SetText(fmt.Sprintf("❌ Invalid hex: %v", err))
+			return
+		}
+	}
+	go func() {
+		var err error
+		if binary {
+			err = session.SendBinary(data)
+		} else {
+			err = session.SendText(text)
+		}
+		if err != nil {
+			r.controlFactory.PostUICallback(func() {
+				r.statusLabel.SetText(fmt.Sprintf("❌ %v", err))
+			})
+		}
+	}()
+}
 
-	// Update body
-	r.responseBody.SetText(resp.Body)
+// sendOptions collects the options for sending the bound request from the project and settings
+func (r *requestPanelGroup) sendOptions() SendOptions {
+	// Get timeout from project settings (default 30000ms if not set)
+	timeoutInMs := int64(30000)
+	if r.content.BoundProject != nil && r.content.BoundProject.Settings.TimeoutInMs > 0 {
+		timeoutInMs = r.content.BoundProject.Settings.TimeoutInMs
+	}
 
-	// Format headers for display
-	var headerLines []string
-	for name, value := range resp.Headers {
-		headerLines = append(headerLines, fmt.Sprintf("%s: %s", name, value))
+	options := SendOptions{
+		Settings:        r.content.Settings,
+		Path:            r.content.Path,
+		TimeoutInMs:     timeoutInMs,
+		FreshConnection: r.content.FreshConn,
+	}
+	if project := r.content.BoundProject; project != nil {
+		baseURL := r.content.BoundRequest.Host
+		options.BaseDir = project.dir()
+		// Cookies are shared by all requests of the project sent to the same environment
+		options.Jar = project.CookieJar(baseURL, r.content.Settings)
+		options.Environment = project.environmentByBaseURL(baseURL)
 	}
-	r.responseHeaders.SetText(strings.Join(headerLines, "\r\n"))
+	// Show the upload progress of large bodies in the status line
+	options.OnUploadProgress = func(sent, total int64) {
+		r.controlFactory.PostUICallback(func() {
+			if total > 0 {
+				r.statusLabel.SetText(fmt.Sprintf("⏳ Uploading %d%% (%s of %s)", sent*100/total, formatSize(sent), formatSize(total)))
+			} else {
+				r.statusLabel.SetText(fmt.Sprintf("⏳ Uploading %s", formatSize(sent)))
+			}
+		})
+	}
+	return options
 }
 
 func createRequestPanel(factory win32.ControlFactory, tabController TabController) *requestPanelGroup {
 	group := &requestPanelGroup{
-		nameLabel:       factory.CreateLabel("Name"),
-		nameInput:       factory.CreateInput(),
-		methodLabel:     factory.CreateLabel("Method"),
-		methodCombo:     factory.CreateComboBox(),
-		envLabel:        factory.CreateLabel("Env"),
-		envCombo:        factory.CreateEditableComboBox(),
-		urlLabel:        factory.CreateLabel("Path"),
-		urlInput:        factory.CreateInput(),
-		queryLabel:      factory.CreateLabel("Query Parameters (one per line: key=value)"),
-		queryInput:      factory.CreateCodeEdit(false),
-		headersLabel:    factory.CreateLabel("Headers (one per line: Header: value)"),
-		headersInput:    factory.CreateCodeEdit(false),
-		bodyLabel:       factory.CreateLabel("Request Body"),
-		bodyInput:       factory.CreateCodeEdit(false),
-		responseLabel:   factory.CreateLabel("Response"),
-		statusLabel:     factory.CreateLabel("Ready"),
-		responseTabCtrl: factory.CreateTabControl(),
-		responseInfo:    factory.CreateLabel(""),
-		responseBody:    factory.CreateCodeEdit(true),
-		responseHeaders: factory.CreateCodeEdit(true),
-		tabController:   tabController,
-		controlFactory:  factory,
+		nameLabel:         factory.CreateLabel("Name"),
+		nameInput:         factory.CreateInput(),
+		methodLabel:       factory.CreateLabel("Method"),
+		methodCombo:       factory.CreateComboBox(),
+		envLabel:          factory.CreateLabel("Env"),
+		envCombo:          factory.CreateEditableComboBox(),
+		urlLabel:          factory.CreateLabel("Path"),
+		urlInput:          factory.CreateInput(),
+		queryLabel:        factory.CreateLabel("Query Parameters (one per line: key: value, # disables)"),
+		queryInput:        factory.CreateCodeEdit(false),
+		queryEncCombo:     factory.CreateComboBox(),
+		headersLabel:      factory.CreateLabel("Headers (one per line: Header: value, # disables)"),
+		headersInput:      factory.CreateCodeEdit(false),
+		bodyLabel:         factory.CreateLabel("Request Body"),
+		bodyInput:         factory.CreateCodeEdit(false),
+		bodyModeCombo:     factory.CreateComboBox(),
+		chunkedChk:        factory.CreateCheckbox("Chunked transfer"),
+		graphQLVarsInput:  factory.CreateCodeEdit(false),
+		graphQLOpLabel:    factory.CreateLabel("Operation"),
+		graphQLOpInput:    factory.CreateInput(),
+		wsMessageInput:    factory.CreateInput(),
+		protoLabel:        factory.CreateLabel("Proto schema / type"),
+		protoSchemaInput:  factory.CreateInput(),
+		protoMessageInput: factory.CreateInput(),
+		authLabel:         factory.CreateLabel("Auth"),
+		authCombo:         factory.CreateComboBox(),
+		authUserLabel:     factory.CreateLabel("User / Key file"),
+		authUserInput:     factory.CreateInput(),
+		authPassLabel:     factory.CreateLabel("Password / Secret"),
+		authPassInput:     factory.CreateInput(),
+		authClaimsLabel:   factory.CreateLabel("JWT Claims"),
+		authClaimsInput:   factory.CreateInput(),
+		snapshotLabel:     factory.CreateLabel("Snapshot"),
+		snapshotChk:       factory.CreateCheckbox("Assert"),
+		snapshotHdrLabel:  factory.CreateLabel("Compared headers"),
+		snapshotHdrInput:  factory.CreateInput(),
+		snapshotIgnLabel:  factory.CreateLabel("Ignore (paths, /regex/, uuid, timestamp; ...)"),
+		snapshotIgnInput:  factory.CreateInput(),
+		freshConnChk:      factory.CreateCheckbox("Fresh connection (cold timings)"),
+		streamingChk:      factory.CreateCheckbox("Stream response"),
+		charsetCombo:      factory.CreateComboBox(),
+		formatterCombo:    factory.CreateComboBox(),
+		responseLabel:     factory.CreateLabel("Response"),
+		statusLabel:       factory.CreateLabel("Ready"),
+		responseTabCtrl:   factory.CreateTabControl(),
+		responseInfo:      factory.CreateLabel(""),
+		filterInput:       factory.CreateInput(),
+		responseBody:      factory.CreateCodeEdit(true),
+		responseHeaders:   factory.CreateCodeEdit(true),
+		responseJWT:       factory.CreateCodeEdit(true),
+		tabController:     tabController,
+		controlFactory:    factory,
 	}
 
 	// Set up tab change handler
@@ -316,11 +1024,149 @@ func createRequestPanel(factory win32.ControlFactory, tabController TabControlle
 
 	group.clearResponseBtn = factory.CreateButton("Clear", func() {
 		if group.content != nil {
+			// Stored responses are removed as well, otherwise they return when the request is reopened
+			if project := group.content.BoundProject; project != nil && project.historyDir() != "" && len(group.content.Responses) > 0 {
+				if factory.MessageBoxYesNo("Clear Responses", "Remove all responses of this request, including the stored history?") != win32.ID_YES {
+					return
+				}
+				if err := project.clearHistory(group.content.BoundRequest); err != nil {
+					factory.MessageBox("Error", err.Error())
+				}
+			}
+			removeBodyFiles(group.content.Responses)
 			group.content.Responses = nil
 			group.updateResponseTabs()
 		}
 	})
 
+	group.bodyFileBtn = factory.CreateButton("File...", func() {
+		if group.content == nil {
+			return
+		}
+		path, ok := factory.OpenFileDialog("Select Body File", "All Files (*.*)|*.*|", "")
+		if !ok {
+			return
+		}
+		// Store the path relative to the project file when possible
+		if project := group.content.BoundProject; project != nil && project.dir() != "" {
+			dir := project.dir()
+			if rel, err := filepath.Rel(dir, path); err == nil && !strings.HasPrefix(rel, "..") {
+				path = rel
+			}
+		}
+		group.SaveState()
+		group.content.BoundRequest.BodyFile = path
+		group.content.BoundRequest.BodyMode = BodyFile
+		group.bodyModeCombo.SetCurSel(slices.Index(bodyModes, BodyFile))
+		group.showBody(group.content.BoundRequest)
+	})
+
+	group.introspectBtn = factory.CreateButton("Introspect", func() {
+		if group.content == nil {
+			return
+		}
+		group.SaveState()
+		project := group.content.BoundProject
+		if project == nil {
+			factory.MessageBox("No Project", "The schema is stored with the project. Please create or open a project first.")
+			return
+		}
+		request := group.content.BoundRequest
+		if request.Host == "" {
+			group.statusLabel.SetText("❌ No environment")
+			return
+		}
+		endpoint := request.Host + group.content.Path
+		group.statusLabel.SetText("⏳ Fetching GraphQL schema...")
+		ctx, cancel := context.WithCancel(context.Background())
+		done := group.trackSend(group.content, cancel)
+		go request.introspectGraphQL(ctx, group.sendOptions(), func(schema json.RawMessage, err error) {
+			factory.PostUICallback(func() {
+				done()
+				if err != nil {
+					group.statusLabel.SetText(fmt.Sprintf("❌ %v", err))
+					return
+				}
+				project.SetGraphQLSchema(endpoint, schema)
+				group.statusLabel.SetText("✅ Schema stored: " + graphQLSchemaSummary(schema))
+			})
+		})
+	})
+
+	group.stopBtn = factory.CreateButton("Stop", func() {
+		if group.content == nil {
+			return
+		}
+		// Cancelling also closes an open WebSocket connection
+		if group.content.cancel != nil {
+			group.content.cancel()
+		}
+	})
+
+	group.protoSchemaBtn = factory.CreateButton("...", func() {
+		if group.content == nil {
+			return
+		}
+		path, ok := factory.OpenFileDialog("Select Protobuf Schema", "Protobuf Schemas (*.proto;*.pb;*.desc;*.protoset)|*.proto;*.pb;*.desc;*.protoset|All Files (*.*)|*.*|", "proto")
+		if !ok {
+			return
+		}
+		// Store the path relative to the project file when possible
+		if project := group.content.BoundProject; project != nil && project.dir() != "" {
+			dir := project.dir()
+			if rel, err := filepath.Rel(dir, path); err == nil && !strings.HasPrefix(rel, "..") {
+				path = rel
+			}
+		}
+		group.protoSchemaInput.SetText(path)
+	})
+
+	group.saveBodyBtn = factory.CreateButton("Save Body...", func() {
+		if group.content == nil || len(group.content.Responses) == 0 {
+			return
+		}
+		index := min(max(group.responseTabCtrl.GetCurSel(), 0), len(group.content.Responses)-1)
+		response := &group.content.Responses[index]
+		if response.StatusCode == 0 {
+			factory.MessageBox("Save Body", "The selected response has no body.")
+			return
+		}
+		path, ok := factory.SaveFileDialog("Save Response Body", "All Files (*.*)|*.*|", "", "response")
+		if !ok {
+			return
+		}
+		if err := response.saveBody(path); err != nil {
+			factory.MessageBox("Error", fmt.Sprintf("Error saving response body: %v", err))
+			return
+		}
+		group.statusLabel.SetText(fmt.Sprintf("💾 Saved %s to %s", formatSize(response.Size), filepath.Base(path)))
+	})
+
+	group.filterBtn = factory.CreateButton("Filter", func() {
+		if group.content == nil || len(group.content.Responses) == 0 {
+			return
+		}
+		index := min(max(group.responseTabCtrl.GetCurSel(), 0), len(group.content.Responses)-1)
+		response := &group.content.Responses[index]
+		response.Filter = strings.TrimSpace(group.filterInput.GetText())
+		group.shownDiff = ""
+		group.responseBody.SetText(response.FilteredBody())
+	})
+
+	group.diffBtn = factory.CreateButton("Diff...", group.showDiffMenu)
+
+	group.wsSendTextBtn = factory.CreateButton("Send Text", func() {
+		group.sendWebSocketMessage(false)
+	})
+	group.wsSendBinaryBtn = factory.CreateButton("Send Hex", func() {
+		group.sendWebSocketMessage(true)
+	})
+	group.wsCloseBtn = factory.CreateButton("Disconnect", func() {
+		if session := group.content.WebSocket; session != nil {
+			go session.Close()
+		}
+	})
+
 	group.manageEnvBtn = factory.CreateButton("Manage...", func() {
 		// TODO: Open environment management dialog
 		factory.MessageBox("Environment Management", "Environment management dialog will be implemented here.")
@@ -381,57 +1227,110 @@ func createRequestPanel(factory win32.ControlFactory, tabController TabControlle
 			return
 		}
 
+		if request.Kind == KindWebSocket {
+			group.connectWebSocket()
+			return
+		}
+
 		group.statusLabel.SetText("⏳ Sending...")
 		group.responseBody.SetText("")
 
-		// Get timeout from project settings (default 30000ms if not set)
-		timeoutInMs := int64(30000)
-		if group.content.BoundProject != nil && group.content.BoundProject.Settings.TimeoutInMs > 0 {
-			timeoutInMs = group.content.BoundProject.Settings.TimeoutInMs
+		options := group.sendOptions()
+		content := group.content
+		project := content.BoundProject
+
+		// Show the events of streamed responses as they arrive
+		live := &liveText{}
+		options.OnStreamEvent = func(event StreamEvent) {
+			if live.add(event.String()) {
+				group.scheduleLiveUpdate(content, live, "📡 Streaming...")
+			}
 		}
 
-		// Send request in background goroutine
-		go request.sendRequest(group.content.Settings, group.content.Path, timeoutInMs, func(responseData *ResponseData, err error) {
+		// Send request in background goroutine; Stop cancels it.
+		// done is set before the UI callback can run, as both happen on the UI thread.
+		var done func()
+		cancel := request.startSend(options, func(responseData *ResponseData, err error) {
 			// Marshal the UI update back to the main thread using PostUICallback
 			factory.PostUICallback(func() {
-				if err != nil {
-					// Create error response data
-					errorResponse := ResponseData{
-						Body:       fmt.Sprintf("Error sending request:\r\n%v", err),
-						Headers:    make(map[string]string),
-						StatusCode: 0,
-						Status:     "Error",
-						Duration:   0,
-						Timestamp:  time.Now(),
+				done()
+				live.finish()
+				if project != nil {
+					if err := project.storeCookiesInSettings(group.content.Settings); err != nil {
+						group.statusLabel.SetText(fmt.Sprintf("⚠ Error saving cookies: %v", err))
 					}
-					group.statusLabel.SetText("❌ Error")
+				}
 
-					// Add error response to the list
-					group.content.Responses = append([]ResponseData{errorResponse}, group.content.Responses...)
-					group.updateResponseTabs()
+				if err != nil {
+					group.addErrorResponse(content, err)
 					return
 				}
 
-				// Add new response to the beginning of the list (newest first)
-				group.content.Responses = append([]ResponseData{*responseData}, group.content.Responses...)
+				// Compare the response with the approved snapshot of the request
+				var snapshotStatus string
+				if project != nil && request.Snapshot != nil {
+					status, err := project.assertSnapshot(request, responseData, content.Settings)
+					if err != nil {
+						status = fmt.Sprintf("⚠ Snapshot: %v", err)
+					}
+					snapshotStatus = responseData.Status + " | " + status
+				}
 
-				// Update the response tabs
-				group.updateResponseTabs()
+				// Add new response to the beginning of the list (newest first)
+				group.addResponse(content, *responseData)
+				if snapshotStatus != "" && group.content == content {
+					group.statusLabel.SetText(snapshotStatus)
+				}
 			})
 		})
+		done = group.trackSend(content, cancel)
 	})
 
-	for _, method := range httpMethods {
+	for _, method := range methodChoices {
 		group.methodCombo.AddString(method)
 	}
 	group.methodCombo.SetCurSel(0)
 
+	for _, authType := range authTypes {
+		group.authCombo.AddString(authType.String())
+	}
+	group.authCombo.SetCurSel(0)
+
+	for _, encoding := range queryEncodings {
+		group.queryEncCombo.AddString(encoding.String())
+	}
+	group.queryEncCombo.SetCurSel(0)
+
+	for _, mode := range bodyModes {
+		group.bodyModeCombo.AddString(mode.String())
+	}
+	group.bodyModeCombo.SetCurSel(0)
+
+	for _, charset := range charsets {
+		group.charsetCombo.AddString(charsetLabel(charset))
+	}
+	group.charsetCombo.SetCurSel(0)
+
+	for _, name := range formatterNames {
+		group.formatterCombo.AddString(formatterLabel(name))
+	}
+	group.formatterCombo.SetCurSel(0)
+
 	group.ControllerGroup = win32.NewControllerGroup(
 		group.nameLabel, group.nameInput,
 		group.methodCombo, group.envCombo, group.urlInput, group.headersInput, group.queryInput, group.bodyInput,
 		group.responseBody, group.responseHeaders, group.responseInfo, group.responseTabCtrl,
 		group.statusLabel, group.sendBtn, group.clearResponseBtn, group.manageEnvBtn, group.appendBtn,
 		group.methodLabel, group.envLabel, group.urlLabel, group.headersLabel, group.queryLabel, group.bodyLabel, group.responseLabel,
+		group.authLabel, group.authCombo, group.authUserLabel, group.authUserInput, group.authPassLabel, group.authPassInput,
+		group.authClaimsLabel, group.authClaimsInput, group.responseJWT, group.freshConnChk,
+		group.queryEncCombo, group.bodyModeCombo, group.bodyFileBtn, group.chunkedChk,
+		group.graphQLVarsInput, group.graphQLOpLabel, group.graphQLOpInput, group.introspectBtn,
+		group.wsMessageInput, group.wsSendTextBtn, group.wsSendBinaryBtn, group.wsCloseBtn,
+		group.protoLabel, group.protoSchemaInput, group.protoSchemaBtn, group.protoMessageInput,
+		group.streamingChk, group.charsetCombo, group.formatterCombo, group.stopBtn, group.saveBodyBtn,
+		group.filterInput, group.filterBtn, group.diffBtn,
+		group.snapshotLabel, group.snapshotChk, group.snapshotHdrLabel, group.snapshotHdrInput, group.snapshotIgnLabel, group.snapshotIgnInput,
 	)
 	return group
 }
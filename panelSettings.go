@@ -2,6 +2,8 @@ package main
 
 import (
 	"fmt"
+	"strconv"
+	"strings"
 
 	"hoermi.com/rest-test/win32"
 )
@@ -22,6 +24,26 @@ type settingsPanelGroup struct {
 	settingsTitle   *win32.Control
 	saveSettingsBtn *win32.ButtonControl
 
+	// Proxy controls
+	proxyTitle     *win32.Control
+	proxyModeCombo *win32.ComboBoxControl
+	proxyAddrLabel *win32.Control
+	proxyAddrInput *win32.Control
+	proxyUserLabel *win32.Control
+	proxyUserInput *win32.Control
+	proxyPassLabel *win32.Control
+	proxyPassInput *win32.Control
+	noProxyLabel   *win32.Control
+	noProxyInput   *win32.Control
+
+	// Response controls
+	responseTitle      *win32.Control
+	maxResponseLabel   *win32.Control
+	maxResponseInput   *win32.Control
+	jsonIndentLabel    *win32.Control
+	jsonIndentInput    *win32.Control
+	updateSnapshotsChk *win32.CheckBoxControl
+
 	content *SettingsTabContent
 }
 
@@ -51,6 +73,45 @@ func (s *settingsPanelGroup) Resize(tabHeight, width, height int32) {
 
 	s.skipVerifyChk.MoveWindow(layoutPadding, y, layoutColumnWidth, layoutInputHeight)
 	y += layoutInputHeight + layoutPadding
+
+	// Proxy section
+	s.proxyTitle.MoveWindow(layoutPadding, y, layoutColumnWidth, layoutLabelHeight+4)
+	y += layoutLabelHeight + layoutPadding
+	s.proxyModeCombo.MoveWindow(layoutPadding, y, layoutColumnWidth, 200)
+	y += layoutInputHeight + layoutPadding
+
+	halfWidth := (layoutColumnWidth - layoutPadding) / 2
+	s.proxyAddrLabel.MoveWindow(layoutPadding, y, layoutColumnWidth, layoutLabelHeight)
+	y += layoutLabelHeight + layoutPadding
+	s.proxyAddrInput.MoveWindow(layoutPadding, y, layoutColumnWidth, layoutInputHeight)
+	y += layoutInputHeight + layoutPadding
+
+	s.proxyUserLabel.MoveWindow(layoutPadding, y, halfWidth, layoutLabelHeight)
+	s.proxyPassLabel.MoveWindow(layoutPadding+halfWidth+layoutPadding, y, halfWidth, layoutLabelHeight)
+	y += layoutLabelHeight + layoutPadding
+	s.proxyUserInput.MoveWindow(layoutPadding, y, halfWidth, layoutInputHeight)
+	s.proxyPassInput.MoveWindow(layoutPadding+halfWidth+layoutPadding, y, halfWidth, layoutInputHeight)
+	y += layoutInputHeight + layoutPadding
+
+	s.noProxyLabel.MoveWindow(layoutPadding, y, layoutColumnWidth, layoutLabelHeight)
+	y += layoutLabelHeight + layoutPadding
+	s.noProxyInput.MoveWindow(layoutPadding, y, layoutColumnWidth, layoutInputHeight)
+	y += layoutInputHeight + layoutPadding
+
+	// Response section
+	s.responseTitle.MoveWindow(layoutPadding, y, layoutColumnWidth, layoutLabelHeight+4)
+	y += layoutLabelHeight + layoutPadding
+	s.maxResponseLabel.MoveWindow(layoutPadding, y, layoutColumnWidth, layoutLabelHeight)
+	y += layoutLabelHeight + layoutPadding
+	s.maxResponseInput.MoveWindow(layoutPadding, y, layoutButtonWidth, layoutInputHeight)
+	y += layoutInputHeight + layoutPadding
+	s.jsonIndentLabel.MoveWindow(layoutPadding, y, layoutColumnWidth, layoutLabelHeight)
+	y += layoutLabelHeight + layoutPadding
+	s.jsonIndentInput.MoveWindow(layoutPadding, y, layoutButtonWidth, layoutInputHeight)
+	y += layoutInputHeight + layoutPadding
+	s.updateSnapshotsChk.MoveWindow(layoutPadding, y, layoutColumnWidth, layoutInputHeight)
+	y += layoutInputHeight + layoutPadding
+
 	s.saveSettingsBtn.MoveWindow(layoutPadding, y, layoutButtonWidth, layoutInputHeight)
 }
 
@@ -59,6 +120,21 @@ func (s *settingsPanelGroup) SaveState() {
 	s.content.Settings.Certificate.KeyFile = s.keyInput.GetText()
 	s.content.Settings.Certificate.CACertFile = s.caInput.GetText()
 	s.content.Settings.Certificate.SkipVerify = s.skipVerifyChk.IsChecked()
+
+	proxy := &s.content.Settings.Proxy
+	if idx := s.proxyModeCombo.GetCurSel(); idx >= 0 && idx < len(proxyModes) {
+		proxy.Mode = proxyModes[idx]
+	}
+	proxy.Address = s.proxyAddrInput.GetText()
+	proxy.Username = s.proxyUserInput.GetText()
+	proxy.Password = s.proxyPassInput.GetText()
+	proxy.NoProxy = s.noProxyInput.GetText()
+
+	// An empty or invalid value restores the default
+	maxResponseMB, _ := strconv.Atoi(strings.TrimSpace(s.maxResponseInput.GetText()))
+	s.content.Settings.MaxResponseMemoryMB = max(maxResponseMB, 0)
+	s.content.Settings.JSONIndent = strings.TrimSpace(s.jsonIndentInput.GetText())
+	s.content.Settings.UpdateSnapshots = s.updateSnapshotsChk.IsChecked()
 }
 
 func (s *settingsPanelGroup) SetState(data any) {
@@ -68,6 +144,21 @@ func (s *settingsPanelGroup) SetState(data any) {
 		s.keyInput.SetText(content.Settings.Certificate.KeyFile)
 		s.caInput.SetText(content.Settings.Certificate.CACertFile)
 		s.skipVerifyChk.SetChecked(content.Settings.Certificate.SkipVerify)
+
+		proxy := content.Settings.Proxy
+		for i, mode := range proxyModes {
+			if mode == proxy.Mode {
+				s.proxyModeCombo.SetCurSel(i)
+			}
+		}
+		s.proxyAddrInput.SetText(proxy.Address)
+		s.proxyUserInput.SetText(proxy.Username)
+		s.proxyPassInput.SetText(proxy.Password)
+		s.noProxyInput.SetText(proxy.NoProxy)
+
+		s.maxResponseInput.SetText(strconv.FormatInt(content.Settings.maxResponseMemory()>>20, 10))
+		s.jsonIndentInput.SetText(content.Settings.JSONIndent)
+		s.updateSnapshotsChk.SetChecked(content.Settings.UpdateSnapshots)
 	}
 }
 
@@ -81,7 +172,29 @@ func createSettingsPanel(factory win32.ControlFactory) *settingsPanelGroup {
 		caLabel:       factory.CreateLabel("CA Bundle (optional)"),
 		caInput:       factory.CreateInput(),
 		skipVerifyChk: factory.CreateCheckbox("Skip TLS Verification (insecure)"),
+
+		proxyTitle:     factory.CreateLabel("Proxy"),
+		proxyModeCombo: factory.CreateComboBox(),
+		proxyAddrLabel: factory.CreateLabel("Proxy address (host:port)"),
+		proxyAddrInput: factory.CreateInput(),
+		proxyUserLabel: factory.CreateLabel("Proxy user (optional)"),
+		proxyUserInput: factory.CreateInput(),
+		proxyPassLabel: factory.CreateLabel("Proxy password"),
+		proxyPassInput: factory.CreateInput(),
+		noProxyLabel:   factory.CreateLabel("No proxy for (comma separated hosts, .domains, CIDRs)"),
+		noProxyInput:   factory.CreateInput(),
+
+		responseTitle:      factory.CreateLabel("Responses"),
+		maxResponseLabel:   factory.CreateLabel("Keep response bodies in memory up to (MB), larger ones go to a temp file"),
+		maxResponseInput:   factory.CreateInput(),
+		jsonIndentLabel:    factory.CreateLabel("Indent formatted JSON by (number of spaces or 'tab', default 2)"),
+		jsonIndentInput:    factory.CreateInput(),
+		updateSnapshotsChk: factory.CreateCheckbox("Update snapshots: approve every response instead of comparing"),
+	}
+	for _, mode := range proxyModes {
+		group.proxyModeCombo.AddString(mode.String())
 	}
+	group.proxyModeCombo.SetCurSel(0)
 	group.certBtn = factory.CreateButton("...", func() {
 		if path, ok := factory.OpenFileDialog("Select Certificate", "PEM Files (*.pem;*.crt)|*.pem;*.crt|All Files (*.*)|*.*|", "pem"); ok {
 			group.certInput.SetText(path)
@@ -101,6 +214,8 @@ func createSettingsPanel(factory win32.ControlFactory) *settingsPanelGroup {
 	})
 	group.saveSettingsBtn = factory.CreateButton("Save Settings", func() {
 		group.SaveState()
+		// Certificate files may have been replaced, rebuild clients on the next send
+		group.content.Settings.invalidateClients()
 		if err := group.content.Settings.save(); err != nil {
 			factory.MessageBox("Error", fmt.Sprintf("Error saving settings: %v", err))
 		}
@@ -110,6 +225,11 @@ func createSettingsPanel(factory win32.ControlFactory) *settingsPanelGroup {
 		group.skipVerifyChk, group.certBtn, group.keyBtn, group.caBtn,
 		group.settingsTitle, group.certLabel, group.keyLabel, group.caLabel,
 		group.saveSettingsBtn,
+		group.proxyTitle, group.proxyModeCombo, group.proxyAddrLabel, group.proxyAddrInput,
+		group.proxyUserLabel, group.proxyUserInput, group.proxyPassLabel, group.proxyPassInput,
+		group.noProxyLabel, group.noProxyInput,
+		group.responseTitle, group.maxResponseLabel, group.maxResponseInput,
+		group.jsonIndentLabel, group.jsonIndentInput, group.updateSnapshotsChk,
 	)
 	return group
 }
@@ -20,4 +20,5 @@ const (
 	PanelProjectView win32.PanelGroupName = "projectView"
 	PanelSettings    win32.PanelGroupName = "settings"
 	PanelWelcome     win32.PanelGroupName = "welcome"
+	PanelReport      win32.PanelGroupName = "report"
 )
@@ -0,0 +1,168 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"maps"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+)
+
+// Param is a single name/value line of a header or query parameter list
+type Param struct {
+	Name     string `json:"name"`
+	Value    string `json:"value"`
+	Disabled bool   `json:"disabled,omitempty"` // Kept in the list but not sent
+}
+
+// Params is an ordered list of name/value pairs in which names may repeat,
+// e.g. several Accept headers or Set-Cookie values
+type Params []Param
+
+// Format renders one "name: value" line per entry; disabled entries are prefixed with '#'
+func (p Params) Format() string {
+	var builder strings.Builder
+	for _, param := range p {
+		if param.Disabled {
+			builder.WriteString("# ")
+		}
+		builder.WriteString(param.Name)
+		builder.WriteString(": ")
+		builder.WriteString(param.Value)
+		builder.WriteString("\r\n")
+	}
+	return builder.String()
+}
+
+// ParseParams parses the lines written by Format; lines starting with '#' are disabled entries
+func ParseParams(input string) Params {
+	params := Params{}
+	lines := strings.SplitSeq(input, "\r\n")
+	for line := range lines {
+		line = strings.TrimSpace(line)
+		if line == "" {
+			continue
+		}
+		disabled := false
+		if rest, ok := strings.CutPrefix(line, "#"); ok {
+			disabled = true
+			line = strings.TrimSpace(rest)
+		}
+		parts := strings.SplitN(line, ":", 2)
+		if len(parts) == 2 {
+			name := strings.TrimSpace(parts[0])
+			value := strings.TrimSpace(parts[1])
+			params = append(params, Param{Name: name, Value: value, Disabled: disabled})
+		}
+	}
+	return params
+}
+
+// QueryEncoding selects how query parameter names and values are encoded
+type QueryEncoding string
+
+const (
+	QueryEncodingForm    QueryEncoding = ""        // application/x-www-form-urlencoded, spaces as '+'
+	QueryEncodingPercent QueryEncoding = "percent" // Percent-encoding, spaces as "%20"
+	QueryEncodingRaw     QueryEncoding = "raw"     // Sent exactly as entered
+)
+
+var queryEncodings = []QueryEncoding{QueryEncodingForm, QueryEncodingPercent, QueryEncodingRaw}
+
+// String returns the display name of the encoding
+func (e QueryEncoding) String() string {
+	switch e {
+	case QueryEncodingPercent:
+		return "Spaces as %20"
+	case QueryEncodingRaw:
+		return "Raw (unencoded)"
+	default:
+		return "Spaces as +"
+	}
+}
+
+// EncodeQuery builds a query string from the enabled entries in their order
+func (p Params) EncodeQuery(encoding QueryEncoding) string {
+	escape := url.QueryEscape
+	switch encoding {
+	case QueryEncodingPercent:
+		// QueryEscape encodes a literal '+' as %2B, so every remaining '+' is a space
+		escape = func(s string) string { return strings.ReplaceAll(url.QueryEscape(s), "+", "%20") }
+	case QueryEncodingRaw:
+		escape = func(s string) string { return s }
+	}
+
+	var builder strings.Builder
+	for _, param := range p {
+		if param.Disabled {
+			continue
+		}
+		if builder.Len() > 0 {
+			builder.WriteByte('&')
+		}
+		builder.WriteString(escape(param.Name))
+		builder.WriteByte('=')
+		builder.WriteString(escape(param.Value))
+	}
+	return builder.String()
+}
+
+// ParamsFromHeader converts HTTP headers into Params keeping every value.
+// net/http does not expose the wire order of distinct names, so names are
+// sorted while the values of a repeated name keep their received order.
+func ParamsFromHeader(header http.Header) Params {
+	params := Params{}
+	for _, name := range slices.Sorted(maps.Keys(header)) {
+		for _, value := range header[name] {
+			params = append(params, Param{Name: name, Value: value})
+		}
+	}
+	return params
+}
+
+// Get returns the first value for the given name (case-insensitive)
+func (p Params) Get(name string) string {
+	for _, param := range p {
+		if strings.EqualFold(param.Name, name) {
+			return param.Value
+		}
+	}
+	return ""
+}
+
+// Values returns all values for the given name (case-insensitive) in order
+func (p Params) Values(name string) []string {
+	var values []string
+	for _, param := range p {
+		if strings.EqualFold(param.Name, name) {
+			values = append(values, param.Value)
+		}
+	}
+	return values
+}
+
+// UnmarshalJSON reads the current list format as well as the object format
+// of older project files, whose keys are ordered alphabetically
+func (p *Params) UnmarshalJSON(data []byte) error {
+	data = bytes.TrimSpace(data)
+	if bytes.HasPrefix(data, []byte("{")) {
+		var legacy map[string]string
+		if err := json.Unmarshal(data, &legacy); err != nil {
+			return err
+		}
+		params := Params{}
+		for _, name := range slices.Sorted(maps.Keys(legacy)) {
+			params = append(params, Param{Name: name, Value: legacy[name]})
+		}
+		*p = params
+		return nil
+	}
+	var params []Param
+	if err := json.Unmarshal(data, &params); err != nil {
+		return err
+	}
+	*p = params
+	return nil
+}
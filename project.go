@@ -3,8 +3,10 @@ package main
 import (
 	"encoding/json"
 	"fmt"
+	"maps"
 	"os"
 	"path/filepath"
+	"slices"
 	"strings"
 )
 
@@ -26,8 +28,9 @@ type CertificateConfig struct {
 
 // Environment represents a deployment environment (dev, staging, prod, etc.)
 type Environment struct {
-	Name    string `json:"name"`    // Display name (e.g., "Development", "Production")
-	BaseURL string `json:"baseUrl"` // Base URL including protocol and host (e.g., "https://api.example.com")
+	Name    string       `json:"name"`            // Display name (e.g., "Development", "Production")
+	BaseURL string       `json:"baseUrl"`         // Base URL including protocol and host (e.g., "https://api.example.com")
+	Proxy   *ProxyConfig `json:"proxy,omitempty"` // Optional: overrides the global proxy settings
 }
 
 func (e *Environment) String() string {
@@ -38,8 +41,11 @@ func (e *Environment) String() string {
 }
 
 type ProjectSettings struct {
-	TimeoutInMs           int64 `json:"timeoutInMs"`           // Request timeout in milliseconds
-	DefaultEnvironmentIdx int   `json:"defaultEnvironmentIdx"` // Index of default environment (-1 for none)
+	TimeoutInMs           int64             `json:"timeoutInMs"`                 // Request timeout in milliseconds
+	DefaultEnvironmentIdx int               `json:"defaultEnvironmentIdx"`       // Index of default environment (-1 for none)
+	CookiePersistence     CookiePersistence `json:"cookiePersistence,omitempty"` // Where cookies are kept between app runs
+	HistoryLimit          int               `json:"historyLimit,omitempty"`      // Responses kept per request, 0 for the default
+	HistoryDays           int               `json:"historyDays,omitempty"`       // Days responses are kept, 0 to keep them regardless of age
 }
 
 // RequestNode represents a node in the hierarchical REST resource tree
@@ -135,12 +141,16 @@ func (node *RequestNode) collectRequests(requests *[]*Request) {
 
 // Project represents a collection of saved requests
 type Project struct {
-	Name         string          `json:"name"`
-	Version      string          `json:"version"`
-	Tree         *RequestNode    `json:"tree"`
-	Settings     ProjectSettings `json:"settings"`
-	Environments []Environment   `json:"environments"` // Available environments
-	filePath     string          // Not saved, tracks where project is stored
+	Name         string                    `json:"name"`
+	Version      string                    `json:"version"`
+	Tree         *RequestNode              `json:"tree"`
+	Settings     ProjectSettings           `json:"settings"`
+	Environments []Environment             `json:"environments"`      // Available environments
+	Cookies      map[string][]StoredCookie `json:"cookies,omitempty"` // Persisted cookies by environment base URL
+	// Introspected GraphQL schemas by endpoint URL
+	GraphQLSchemas map[string]json.RawMessage `json:"graphqlSchemas,omitempty"`
+	filePath       string                     // Not saved, tracks where project is stored
+	cookieJars     map[string]*CookieJar      // Not saved, cookie jars by environment base URL
 }
 
 // NewProject creates a new empty project
@@ -172,8 +182,110 @@ func (p *Project) getDefaultHost() string {
 	return ""
 }
 
+// CookieJar returns the cookie jar of the environment with the given base URL.
+// The jar is created on first use and seeded from the configured persistence.
+func (p *Project) CookieJar(baseURL string, settings *Settings) *CookieJar {
+	if jar, ok := p.cookieJars[baseURL]; ok {
+		return jar
+	}
+	if p.cookieJars == nil {
+		p.cookieJars = make(map[string]*CookieJar)
+	}
+	jar := NewCookieJar(p.persistedCookies(settings)[baseURL])
+	p.cookieJars[baseURL] = jar
+	return jar
+}
+
+// persistedCookies returns the cookies persisted by the configured persistence, by environment base URL
+func (p *Project) persistedCookies(settings *Settings) map[string][]StoredCookie {
+	switch p.Settings.CookiePersistence {
+	case CookiePersistProject:
+		return p.Cookies
+	case CookiePersistSettings:
+		if settings != nil && p.filePath != "" {
+			return settings.Cookies[p.filePath]
+		}
+	}
+	return nil
+}
+
+// CookieJarURLs returns the base URLs of all cookie jars, in use or persisted, sorted
+func (p *Project) CookieJarURLs(settings *Settings) []string {
+	urls := slices.Collect(maps.Keys(p.cookieJars))
+	for baseURL := range p.persistedCookies(settings) {
+		if _, ok := p.cookieJars[baseURL]; !ok {
+			urls = append(urls, baseURL)
+		}
+	}
+	slices.Sort(urls)
+	return urls
+}
+
+// collectCookies returns the persisted cookies updated with the content of the cookie jars.
+// Environments without a jar in this session keep their persisted cookies.
+func (p *Project) collectCookies(persisted map[string][]StoredCookie) map[string][]StoredCookie {
+	cookies := maps.Clone(persisted)
+	if cookies == nil {
+		cookies = make(map[string][]StoredCookie)
+	}
+	for baseURL, jar := range p.cookieJars {
+		if list := jar.List(); len(list) > 0 {
+			cookies[baseURL] = list
+		} else {
+			delete(cookies, baseURL)
+		}
+	}
+	return cookies
+}
+
+// storeCookiesInSettings writes the cookie jars to the user settings if configured so
+// and a jar changed since they were last written
+func (p *Project) storeCookiesInSettings(settings *Settings) error {
+	if p.Settings.CookiePersistence != CookiePersistSettings || settings == nil || p.filePath == "" {
+		return nil
+	}
+	changed := false
+	for _, jar := range p.cookieJars {
+		// Every jar is checked to reset its flag
+		changed = jar.takeChanged() || changed
+	}
+	if !changed {
+		return nil
+	}
+	if settings.Cookies == nil {
+		settings.Cookies = make(map[string]map[string][]StoredCookie)
+	}
+	settings.Cookies[p.filePath] = p.collectCookies(settings.Cookies[p.filePath])
+	return settings.save()
+}
+
+// dir returns the directory of the project file, empty if the project was not saved yet
+func (p *Project) dir() string {
+	if p.filePath == "" {
+		return ""
+	}
+	return filepath.Dir(p.filePath)
+}
+
+// environmentByBaseURL returns the environment with the given base URL, or nil
+func (p *Project) environmentByBaseURL(baseURL string) *Environment {
+	for i := range p.Environments {
+		if p.Environments[i].BaseURL == baseURL {
+			return &p.Environments[i]
+		}
+	}
+	return nil
+}
+
 // Save saves the project to a file
 func (p *Project) Save(filePath string) error {
+	// Cookies are only written to the project if configured so
+	if p.Settings.CookiePersistence == CookiePersistProject {
+		p.Cookies = p.collectCookies(p.Cookies)
+	} else {
+		p.Cookies = nil
+	}
+
 	data, err := json.MarshalIndent(p, "", "  ")
 	if err != nil {
 		return err
@@ -206,6 +318,8 @@ func LoadProject(filePath string) (*Project, error) {
 	}
 
 	project.filePath = filePath
+	// Requests of older project files get an ID, kept once the project is saved
+	project.assignRequestIDs()
 
 	return &project, nil
 }
@@ -16,6 +16,8 @@ type ProjectWindow struct {
 	currentProject *Project
 	// Global settings
 	settings *Settings
+	// Panel of report tabs, which runs the work producing a report
+	reportPanel *reportPanelGroup
 }
 
 type TabController interface {
@@ -26,6 +28,7 @@ type TabController interface {
 	createRequestTab(req *Request, path string)
 	createPendingRequestTab(req *Request, path string)
 	refreshProjectViewTab()
+	createReportTab(title, fileName string, job reportJob)
 }
 
 type ProjectManager interface {
@@ -49,6 +52,8 @@ func NewProjectWindow() *ProjectWindow {
 	panels.Add(PanelProjectView, createProjectViewPanel(pw.mainWindow, pw, pw))
 	panels.Add(PanelSettings, createSettingsPanel(pw.mainWindow))
 	panels.Add(PanelWelcome, createWelcomePanel(pw.mainWindow, pw))
+	pw.reportPanel = createReportPanel(pw.mainWindow)
+	panels.Add(PanelReport, pw.reportPanel)
 
 	settings, err := InitSettings()
 	if err != nil {
@@ -61,7 +66,11 @@ func NewProjectWindow() *ProjectWindow {
 	mainWindow.OnCommand = tabs.GetPanels().HandleCommand
 	// Handle window resizing
 	mainWindow.OnResize = tabs.GetPanels().Resize
-	tabs.OnTabClosed = func() {
+	tabs.OnTabClosed = func(data any) {
+		// A report still being produced is not shown anywhere anymore
+		if content, ok := data.(*ReportTabContent); ok && content.cancel != nil {
+			content.cancel()
+		}
 		// If no tabs left, show welcome tab
 		if tabs.GetTabCount() == 0 {
 			pw.createWelcomeTab()
@@ -207,10 +216,18 @@ func (pw *ProjectWindow) createRequestTabInternal(req *Request, path string, pen
 		Settings:     pw.settings,
 		Pending:      pending,
 	}
+	// Show the stored responses of the request
+	if !pending && pw.currentProject != nil {
+		responses, err := pw.currentProject.loadHistory(req, pw.settings)
+		if err != nil {
+			pw.mainWindow.MessageBox("Warning", fmt.Sprintf("Error loading response history: %v", err))
+		}
+		content.Responses = responses
+	}
 
 	name := "New Request"
 	if !pending {
-		name = req.Method + " " + req.Name
+		name = req.methodLabel() + " " + req.Name
 	}
 	pw.tabs.AddTab(name, content, PanelRequest)
 }
@@ -233,6 +250,7 @@ func (pw *ProjectWindow) createProjectViewTab() {
 	// Create new tab
 	content := &ProjectViewTabContent{
 		BoundProject:  pw.currentProject, // Bind to current project
+		Settings:      pw.settings,
 		SelectedIndex: -1,
 	}
 	pw.tabs.AddTab("📁 Project", content, PanelProjectView)
@@ -264,6 +282,16 @@ func (pw *ProjectWindow) createWelcomeTab() {
 	pw.tabs.AddTab("Welcome", content, PanelWelcome)
 }
 
+// createReportTab creates a tab showing the progress of a job and then the report it produced
+func (pw *ProjectWindow) createReportTab(title, fileName string, job reportJob) {
+	content := &ReportTabContent{
+		Status:   "⏳ Starting...",
+		FileName: fileName,
+	}
+	pw.tabs.AddTab(title, content, PanelReport)
+	pw.reportPanel.start(content, job)
+}
+
 // refreshProjectViewTab refreshes the project view tab if it exists
 func (pw *ProjectWindow) refreshProjectViewTab() {
 	// Find the project view tab
@@ -0,0 +1,492 @@
+package main
+
+import (
+	"encoding/binary"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// ProtobufConfig attaches a schema to decode protobuf responses of a request
+type ProtobufConfig struct {
+	SchemaFile string `json:"schemaFile,omitempty"` // .proto file or descriptor set (protoc -o), relative to the project file
+	Message    string `json:"message,omitempty"`    // Message type of the response, the first message of the schema if empty
+}
+
+// Field types of FieldDescriptorProto
+const (
+	protoDouble   = 1
+	protoFloat    = 2
+	protoInt64    = 3
+	protoUint64   = 4
+	protoInt32    = 5
+	protoFixed64  = 6
+	protoFixed32  = 7
+	protoBool     = 8
+	protoString   = 9
+	protoGroup    = 10
+	protoMessage  = 11
+	protoBytes    = 12
+	protoUint32   = 13
+	protoEnum     = 14
+	protoSfixed32 = 15
+	protoSfixed64 = 16
+	protoSint32   = 17
+	protoSint64   = 18
+)
+
+// protoScalarTypes maps the scalar type names of .proto files to field types
+var protoScalarTypes = map[string]int{
+	"double": protoDouble, "float": protoFloat, "int64": protoInt64, "uint64": protoUint64,
+	"int32": protoInt32, "fixed64": protoFixed64, "fixed32": protoFixed32, "bool": protoBool,
+	"string": protoString, "bytes": protoBytes, "uint32": protoUint32, "sfixed32": protoSfixed32,
+	"sfixed64": protoSfixed64, "sint32": protoSint32, "sint64": protoSint64,
+}
+
+// protoSchema holds the message and enum types of a schema by their full names
+type protoSchema struct {
+	messages map[string]*protoMessageType
+	enums    map[string]map[int64]string
+	first    string // Full name of the first message
+}
+
+type protoMessageType struct {
+	name     string
+	fields   map[uint64]*protoField
+	mapEntry bool // Synthesized entry type of a map field
+}
+
+type protoField struct {
+	name     string
+	jsonName string
+	kind     int
+	repeated bool
+	typeName string // Full name of a message or enum type
+}
+
+// loadProtoSchema reads a .proto file or a binary descriptor set
+func loadProtoSchema(path string) (*protoSchema, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	schema := &protoSchema{messages: map[string]*protoMessageType{}, enums: map[string]map[int64]string{}}
+	if strings.EqualFold(filepath.Ext(path), ".proto") {
+		err = schema.parseProtoFile(string(data))
+	} else {
+		err = schema.parseDescriptorSet(data)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("invalid schema %s: %v", filepath.Base(path), err)
+	}
+	if len(schema.messages) == 0 {
+		return nil, fmt.Errorf("schema %s defines no messages", filepath.Base(path))
+	}
+	return schema, nil
+}
+
+// message returns the message type by full or unqualified name, or the first message if name is empty
+func (schema *protoSchema) message(name string) (*protoMessageType, error) {
+	name = strings.TrimPrefix(strings.TrimSpace(name), ".")
+	if name == "" {
+		name = schema.first
+	}
+	if message, ok := schema.messages[name]; ok {
+		return message, nil
+	}
+	for fullName, message := range schema.messages {
+		if strings.HasSuffix(fullName, "."+name) {
+			return message, nil
+		}
+	}
+	return nil, fmt.Errorf("message %s not found in schema", name)
+}
+
+// protoWire reads protobuf wire format
+type protoWire struct {
+	data []byte
+	pos  int
+}
+
+func (w *protoWire) varint() (uint64, error) {
+	value, n := binary.Uvarint(w.data[w.pos:])
+	if n <= 0 {
+		return 0, fmt.Errorf("invalid varint")
+	}
+	w.pos += n
+	return value, nil
+}
+
+func (w *protoWire) fixed(size int) (uint64, error) {
+	if len(w.data)-w.pos < size {
+		return 0, errTruncatedData
+	}
+	b := w.data[w.pos : w.pos+size]
+	w.pos += size
+	if size == 4 {
+		return uint64(binary.LittleEndian.Uint32(b)), nil
+	}
+	return binary.LittleEndian.Uint64(b), nil
+}
+
+func (w *protoWire) bytes() ([]byte, error) {
+	length, err := w.varint()
+	if err != nil {
+		return nil, err
+	}
+	if length > uint64(len(w.data)-w.pos) {
+		return nil, errTruncatedData
+	}
+	b := w.data[w.pos : w.pos+int(length)]
+	w.pos += int(length)
+	return b, nil
+}
+
+// protoRecord is a field occurrence on the wire
+type protoRecord struct {
+	number   uint64
+	wireType uint64
+	value    uint64 // Varint and fixed values
+	data     []byte // Length delimited content and groups
+}
+
+// protoRecords splits a message into its field occurrences
+func protoRecords(data []byte) ([]protoRecord, error) {
+	var records []protoRecord
+	wire := &protoWire{data: data}
+	for wire.pos < len(data) {
+		tag, err := wire.varint()
+		if err != nil {
+			return nil, err
+		}
+		record := protoRecord{number: tag >> 3, wireType: tag & 7}
+		if record.number == 0 {
+			return nil, fmt.Errorf("invalid field number 0")
+		}
+		switch record.wireType {
+		case 0:
+			record.value, err = wire.varint()
+		case 1:
+			record.value, err = wire.fixed(8)
+		case 2:
+			record.data, err = wire.bytes()
+		case 3:
+			record.data, err = wire.group(record.number, 1)
+		case 5:
+			record.value, err = wire.fixed(4)
+		default:
+			err = fmt.Errorf("invalid wire type %d", record.wireType)
+		}
+		if err != nil {
+			return nil, err
+		}
+		records = append(records, record)
+	}
+	return records, nil
+}
+
+// group returns the content of a group up to its end tag. depth counts the groups
+// the group is nested in, which are limited like the containers of other formats.
+func (w *protoWire) group(number uint64, depth int) ([]byte, error) {
+	if depth > maxDecodeDepth {
+		return nil, fmt.Errorf("groups nested too deeply")
+	}
+	start := w.pos
+	for w.pos < len(w.data) {
+		end := w.pos
+		tag, err := w.varint()
+		if err != nil {
+			return nil, err
+		}
+		switch tag & 7 {
+		case 0:
+			_, err = w.varint()
+		case 1:
+			_, err = w.fixed(8)
+		case 2:
+			_, err = w.bytes()
+		case 3:
+			_, err = w.group(tag>>3, depth+1)
+		case 4:
+			if tag>>3 == number {
+				return w.data[start:end], nil
+			}
+		case 5:
+			_, err = w.fixed(4)
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+	return nil, errTruncatedData
+}
+
+// decodeProtobuf renders a protobuf message as JSON. Without a message type the
+// fields are keyed by their numbers and their types are guessed from the wire format.
+func decodeProtobuf(data []byte, schema *protoSchema, message *protoMessageType, options formatOptions) (string, bool) {
+	decoder := &serialDecoder{}
+	if err := decoder.protoMessage(data, schema, message); err != nil {
+		return "", false
+	}
+	return formatJSON(decoder.out, options.jsonIndent)
+}
+
+// protoMessage writes a message as JSON object; repeated fields become arrays
+func (d *serialDecoder) protoMessage(data []byte, schema *protoSchema, message *protoMessageType) error {
+	if err := d.enter(); err != nil {
+		return err
+	}
+	records, err := protoRecords(data)
+	if err != nil {
+		return err
+	}
+	// Group the occurrences by field, in the order of their first occurrence
+	var numbers []uint64
+	occurrences := map[uint64][]protoRecord{}
+	for _, record := range records {
+		if _, seen := occurrences[record.number]; !seen {
+			numbers = append(numbers, record.number)
+		}
+		occurrences[record.number] = append(occurrences[record.number], record)
+	}
+
+	d.out = append(d.out, '{')
+	for i, number := range numbers {
+		if i > 0 {
+			d.out = append(d.out, ',')
+		}
+		var field *protoField
+		if message != nil {
+			field = message.fields[number]
+		}
+		if field == nil {
+			d.out = appendJSONString(d.out, strconv.FormatUint(number, 10))
+			d.out = append(d.out, ':')
+			if err := d.protoUnknown(occurrences[number]); err != nil {
+				return err
+			}
+			continue
+		}
+		d.out = appendJSONString(d.out, field.jsonName)
+		d.out = append(d.out, ':')
+		if err := d.protoField(field, occurrences[number], schema); err != nil {
+			return err
+		}
+	}
+	d.out = append(d.out, '}')
+	d.depth--
+	return nil
+}
+
+// protoField writes the value of a known field
+func (d *serialDecoder) protoField(field *protoField, records []protoRecord, schema *protoSchema) error {
+	var entryType *protoMessageType
+	if field.kind == protoMessage {
+		entryType = schema.messages[field.typeName]
+	}
+	if entryType != nil && entryType.mapEntry {
+		return d.protoMap(entryType, records, schema)
+	}
+
+	var values []func() error
+	for _, record := range records {
+		if record.wireType == 2 && protoPackable(field.kind) {
+			// Packed repeated scalars
+			wire := &protoWire{data: record.data}
+			for wire.pos < len(wire.data) {
+				var value uint64
+				var err error
+				switch protoWireType(field.kind) {
+				case 1:
+					value, err = wire.fixed(8)
+				case 5:
+					value, err = wire.fixed(4)
+				default:
+					value, err = wire.varint()
+				}
+				if err != nil {
+					return err
+				}
+				values = append(values, func() error {
+					d.protoScalar(field, value, schema)
+					return nil
+				})
+			}
+			continue
+		}
+		values = append(values, func() error {
+			if !protoPackable(field.kind) && record.wireType != 2 && record.wireType != 3 {
+				// The wire type does not match the schema, e.g. an unresolved imported enum
+				return d.protoUnknown([]protoRecord{record})
+			}
+			switch field.kind {
+			case protoString:
+				d.out = appendJSONString(d.out, string(record.data))
+			case protoBytes:
+				d.out = appendJSONBytes(d.out, record.data)
+			case protoMessage, protoGroup:
+				return d.protoMessage(record.data, schema, schema.messages[field.typeName])
+			default:
+				d.protoScalar(field, record.value, schema)
+			}
+			return nil
+		})
+	}
+	if !field.repeated {
+		if len(values) == 0 {
+			d.out = append(d.out, "null"...)
+			return nil
+		}
+		// The last occurrence of a singular field wins
+		return values[len(values)-1]()
+	}
+	d.out = append(d.out, '[')
+	for i, value := range values {
+		if i > 0 {
+			d.out = append(d.out, ',')
+		}
+		if err := value(); err != nil {
+			return err
+		}
+	}
+	d.out = append(d.out, ']')
+	return nil
+}
+
+// protoMap writes the entries of a map field as JSON object
+func (d *serialDecoder) protoMap(entryType *protoMessageType, records []protoRecord, schema *protoSchema) error {
+	d.out = append(d.out, '{')
+	for i, record := range records {
+		if i > 0 {
+			d.out = append(d.out, ',')
+		}
+		// Decode key and value separately to write them as "key": value
+		fields, err := protoRecords(record.data)
+		if err != nil {
+			return err
+		}
+		key := &serialDecoder{depth: d.depth}
+		value := &serialDecoder{depth: d.depth}
+		for _, part := range fields {
+			target := key
+			if part.number == 2 {
+				target = value
+			} else if part.number != 1 {
+				continue
+			}
+			target.out = target.out[:0]
+			if field := entryType.fields[part.number]; field != nil {
+				if err := target.protoField(field, []protoRecord{part}, schema); err != nil {
+					return err
+				}
+			}
+		}
+		// Keys are strings or numbers; JSON object keys are strings
+		if len(key.out) > 0 && key.out[0] == '"' {
+			d.out = append(d.out, key.out...)
+		} else {
+			d.out = appendJSONString(d.out, string(key.out))
+		}
+		d.out = append(d.out, ':')
+		if len(value.out) == 0 {
+			value.out = []byte("null")
+		}
+		d.out = append(d.out, value.out...)
+	}
+	d.out = append(d.out, '}')
+	return nil
+}
+
+// protoScalar writes a varint or fixed value according to the field type
+func (d *serialDecoder) protoScalar(field *protoField, value uint64, schema *protoSchema) {
+	switch field.kind {
+	case protoDouble:
+		d.out = appendJSONFloat(d.out, math.Float64frombits(value), 64)
+	case protoFloat:
+		d.out = appendJSONFloat(d.out, float64(math.Float32frombits(uint32(value))), 32)
+	case protoInt64, protoSfixed64:
+		d.out = strconv.AppendInt(d.out, int64(value), 10)
+	case protoInt32, protoSfixed32:
+		d.out = strconv.AppendInt(d.out, int64(int32(value)), 10)
+	case protoSint32, protoSint64:
+		d.out = strconv.AppendInt(d.out, int64(value>>1)^-int64(value&1), 10)
+	case protoBool:
+		d.out = strconv.AppendBool(d.out, value != 0)
+	case protoEnum:
+		if name, ok := schema.enums[field.typeName][int64(int32(value))]; ok {
+			d.out = appendJSONString(d.out, name)
+		} else {
+			d.out = strconv.AppendInt(d.out, int64(int32(value)), 10)
+		}
+	default:
+		d.out = strconv.AppendUint(d.out, value, 10)
+	}
+}
+
+// protoPackable reports whether values of the type may be packed
+func protoPackable(kind int) bool {
+	return kind != protoString && kind != protoBytes && kind != protoMessage && kind != protoGroup
+}
+
+// protoWireType returns the wire type of a scalar field type
+func protoWireType(kind int) int {
+	switch kind {
+	case protoDouble, protoFixed64, protoSfixed64:
+		return 1
+	case protoFloat, protoFixed32, protoSfixed32:
+		return 5
+	}
+	return 0
+}
+
+// protoUnknown writes a field without schema. Length delimited values are shown
+// as nested message if they parse as one, else as text or base64 bytes.
+func (d *serialDecoder) protoUnknown(records []protoRecord) error {
+	if len(records) > 1 {
+		d.out = append(d.out, '[')
+	}
+	for i, record := range records {
+		if i > 0 {
+			d.out = append(d.out, ',')
+		}
+		switch record.wireType {
+		case 2, 3:
+			if record.wireType == 3 || !protoLooksLikeText(record.data) {
+				nested := &serialDecoder{depth: d.depth}
+				if len(record.data) > 0 && nested.protoMessage(record.data, nil, nil) == nil {
+					d.out = append(d.out, nested.out...)
+					continue
+				}
+			}
+			if protoLooksLikeText(record.data) {
+				d.out = appendJSONString(d.out, string(record.data))
+			} else {
+				d.out = appendJSONBytes(d.out, record.data)
+			}
+		default:
+			d.out = strconv.AppendUint(d.out, record.value, 10)
+		}
+	}
+	if len(records) > 1 {
+		d.out = append(d.out, ']')
+	}
+	return nil
+}
+
+// protoLooksLikeText reports whether data is printable UTF-8 text
+func protoLooksLikeText(data []byte) bool {
+	if !utf8.Valid(data) {
+		return false
+	}
+	for _, r := range string(data) {
+		if !unicode.IsPrint(r) && !unicode.IsSpace(r) {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,530 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// newProtoMessageType registers a message type under its full name
+func (schema *protoSchema) newProtoMessageType(fullName string) *protoMessageType {
+	message := &protoMessageType{name: fullName, fields: map[uint64]*protoField{}}
+	schema.messages[fullName] = message
+	if schema.first == "" {
+		schema.first = fullName
+	}
+	return message
+}
+
+// protoJSONName converts a field name to the lowerCamelCase name used by the JSON mapping
+func protoJSONName(name string) string {
+	var builder strings.Builder
+	upper := false
+	for _, r := range name {
+		switch {
+		case r == '_':
+			upper = true
+		case upper:
+			builder.WriteRune(unicode.ToUpper(r))
+			upper = false
+		default:
+			builder.WriteRune(r)
+		}
+	}
+	return builder.String()
+}
+
+// protoFullName joins a scope and a name
+func protoFullName(scope, name string) string {
+	if scope == "" {
+		return name
+	}
+	return scope + "." + name
+}
+
+// parseDescriptorSet reads a FileDescriptorSet as written by protoc --descriptor_set_out
+func (schema *protoSchema) parseDescriptorSet(data []byte) error {
+	files, err := protoRecords(data)
+	if err != nil {
+		return err
+	}
+	for _, file := range files {
+		if file.number != 1 || file.wireType != 2 {
+			continue
+		}
+		fields, err := protoRecords(file.data)
+		if err != nil {
+			return err
+		}
+		pkg := ""
+		for _, field := range fields {
+			if field.number == 2 && field.wireType == 2 {
+				pkg = string(field.data)
+			}
+			if field.number == 4 {
+				// Dependencies come first, the default message is that of the last file
+				schema.first = ""
+			}
+		}
+		for _, field := range fields {
+			var err error
+			switch {
+			case field.number == 4 && field.wireType == 2:
+				err = schema.parseDescriptor(pkg, field.data)
+			case field.number == 5 && field.wireType == 2:
+				err = schema.parseEnumDescriptor(pkg, field.data)
+			}
+			if err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// parseDescriptor reads a DescriptorProto with its nested types
+func (schema *protoSchema) parseDescriptor(scope string, data []byte) error {
+	records, err := protoRecords(data)
+	if err != nil {
+		return err
+	}
+	var message *protoMessageType
+	for _, record := range records {
+		if record.number == 1 && record.wireType == 2 {
+			message = schema.newProtoMessageType(protoFullName(scope, string(record.data)))
+		}
+	}
+	if message == nil {
+		return fmt.Errorf("message without name")
+	}
+	for _, record := range records {
+		if record.wireType != 2 {
+			continue
+		}
+		switch record.number {
+		case 2:
+			field := &protoField{}
+			var number uint64
+			parts, err := protoRecords(record.data)
+			if err != nil {
+				return err
+			}
+			for _, part := range parts {
+				switch part.number {
+				case 1:
+					field.name = string(part.data)
+				case 3:
+					number = part.value
+				case 4:
+					field.repeated = part.value == 3
+				case 5:
+					field.kind = int(part.value)
+				case 6:
+					field.typeName = strings.TrimPrefix(string(part.data), ".")
+				case 10:
+					field.jsonName = string(part.data)
+				}
+			}
+			if field.jsonName == "" {
+				field.jsonName = protoJSONName(field.name)
+			}
+			message.fields[number] = field
+		case 3:
+			err = schema.parseDescriptor(message.name, record.data)
+		case 4:
+			err = schema.parseEnumDescriptor(message.name, record.data)
+		case 7:
+			// MessageOptions.map_entry
+			options, _ := protoRecords(record.data)
+			for _, option := range options {
+				if option.number == 7 && option.value != 0 {
+					message.mapEntry = true
+				}
+			}
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// parseEnumDescriptor reads an EnumDescriptorProto
+func (schema *protoSchema) parseEnumDescriptor(scope string, data []byte) error {
+	records, err := protoRecords(data)
+	if err != nil {
+		return err
+	}
+	name := ""
+	values := map[int64]string{}
+	for _, record := range records {
+		switch {
+		case record.number == 1 && record.wireType == 2:
+			name = string(record.data)
+		case record.number == 2 && record.wireType == 2:
+			parts, err := protoRecords(record.data)
+			if err != nil {
+				return err
+			}
+			valueName, number := "", int64(0)
+			for _, part := range parts {
+				switch part.number {
+				case 1:
+					valueName = string(part.data)
+				case 2:
+					number = int64(int32(part.value))
+				}
+			}
+			values[number] = valueName
+		}
+	}
+	schema.enums[protoFullName(scope, name)] = values
+	return nil
+}
+
+// protoParser reads the message and enum definitions of a .proto file.
+// Services, options and extensions are skipped; imported types are not resolved
+// and fields of such types are decoded without schema.
+type protoParser struct {
+	tokens []string
+	pos    int
+	schema *protoSchema
+	// Fields whose type is resolved once all types are known, with the scope they were declared in
+	unresolved []protoUnresolved
+}
+
+type protoUnresolved struct {
+	field *protoField
+	scope string
+	name  string
+}
+
+// tokenizeProto splits a .proto file into identifiers, numbers, strings and symbols
+func tokenizeProto(src string) ([]string, error) {
+	var tokens []string
+	for i := 0; i < len(src); {
+		c := src[i]
+		switch {
+		case c == ' ' || c == '\t' || c == '\r' || c == '\n':
+			i++
+		case strings.HasPrefix(src[i:], "//"):
+			end := strings.IndexByte(src[i:], '\n')
+			if end < 0 {
+				end = len(src) - i
+			}
+			i += end
+		case strings.HasPrefix(src[i:], "/*"):
+			end := strings.Index(src[i+2:], "*/")
+			if end < 0 {
+				return nil, fmt.Errorf("unterminated comment")
+			}
+			i += end + 4
+		case c == '"' || c == '\'':
+			end := i + 1
+			for end < len(src) && src[end] != c {
+				if src[end] == '\\' {
+					end++
+				}
+				end++
+			}
+			if end >= len(src) {
+				return nil, fmt.Errorf("unterminated string")
+			}
+			tokens = append(tokens, src[i:end+1])
+			i = end + 1
+		case c == '_' || c == '.' || c == '-' || c == '+' || unicode.IsLetter(rune(c)) || unicode.IsDigit(rune(c)):
+			end := i + 1
+			for end < len(src) && (src[end] == '_' || src[end] == '.' || unicode.IsLetter(rune(src[end])) || unicode.IsDigit(rune(src[end]))) {
+				end++
+			}
+			tokens = append(tokens, src[i:end])
+			i = end
+		default:
+			tokens = append(tokens, string(c))
+			i++
+		}
+	}
+	return tokens, nil
+}
+
+func (p *protoParser) done() bool {
+	return p.pos >= len(p.tokens)
+}
+
+func (p *protoParser) next() string {
+	if p.done() {
+		return ""
+	}
+	token := p.tokens[p.pos]
+	p.pos++
+	return token
+}
+
+func (p *protoParser) peek() string {
+	if p.done() {
+		return ""
+	}
+	return p.tokens[p.pos]
+}
+
+func (p *protoParser) expect(token string) error {
+	if got := p.next(); got != token {
+		return fmt.Errorf("expected %q, found %q", token, got)
+	}
+	return nil
+}
+
+// skipStatement skips up to and including the next ';', along with any nested braces
+func (p *protoParser) skipStatement() {
+	depth := 0
+	for !p.done() {
+		switch p.next() {
+		case "{":
+			depth++
+		case "}":
+			depth--
+			if depth == 0 {
+				return
+			}
+		case ";":
+			if depth == 0 {
+				return
+			}
+		}
+	}
+}
+
+// skipOptions skips field options in brackets
+func (p *protoParser) skipOptions() {
+	if p.peek() != "[" {
+		return
+	}
+	for !p.done() && p.next() != "]" {
+	}
+}
+
+// parseProtoFile reads the definitions of a .proto file
+func (schema *protoSchema) parseProtoFile(src string) error {
+	tokens, err := tokenizeProto(src)
+	if err != nil {
+		return err
+	}
+	p := &protoParser{tokens: tokens, schema: schema}
+	pkg := ""
+	for !p.done() {
+		var err error
+		switch token := p.next(); token {
+		case "package":
+			pkg = p.next()
+			err = p.expect(";")
+		case "message":
+			err = p.parseMessage(pkg)
+		case "enum":
+			err = p.parseEnum(pkg)
+		case ";":
+		default:
+			// syntax, import, option, service, extend
+			p.skipStatement()
+		}
+		if err != nil {
+			return err
+		}
+	}
+	p.resolve()
+	return nil
+}
+
+// parseMessage reads a message definition after the "message" keyword
+func (p *protoParser) parseMessage(scope string) error {
+	message := p.schema.newProtoMessageType(protoFullName(scope, p.next()))
+	if err := p.expect("{"); err != nil {
+		return err
+	}
+	inOneof := false
+	for {
+		var err error
+		switch token := p.next(); token {
+		case "":
+			return fmt.Errorf("unterminated message %s", message.name)
+		case "}":
+			if inOneof {
+				inOneof = false
+				continue
+			}
+			return nil
+		case "message":
+			err = p.parseMessage(message.name)
+		case "enum":
+			err = p.parseEnum(message.name)
+		case "oneof":
+			p.next()
+			err = p.expect("{")
+			inOneof = true
+		case "map":
+			err = p.parseMapField(message)
+		case "option", "reserved", "extensions", "extend":
+			p.skipStatement()
+		case ";":
+		default:
+			err = p.parseField(message, token)
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
+
+// parseField reads a field definition starting with its label or type
+func (p *protoParser) parseField(message *protoMessageType, token string) error {
+	field := &protoField{}
+	if token == "repeated" || token == "optional" || token == "required" {
+		field.repeated = token == "repeated"
+		token = p.next()
+	}
+	typeName := token
+	if typeName == "group" {
+		return fmt.Errorf("proto2 groups are not supported")
+	}
+	field.name = p.next()
+	if err := p.expect("="); err != nil {
+		return err
+	}
+	number, err := strconv.ParseUint(p.next(), 0, 32)
+	if err != nil {
+		return fmt.Errorf("invalid number of field %s", field.name)
+	}
+	p.skipOptions()
+	if err := p.expect(";"); err != nil {
+		return err
+	}
+	field.jsonName = protoJSONName(field.name)
+	if kind, ok := protoScalarTypes[typeName]; ok {
+		field.kind = kind
+	} else {
+		p.unresolved = append(p.unresolved, protoUnresolved{field: field, scope: message.name, name: typeName})
+	}
+	message.fields[number] = field
+	return nil
+}
+
+// parseMapField reads "map<K, V> name = N;" and adds its entry message type
+func (p *protoParser) parseMapField(message *protoMessageType) error {
+	if err := p.expect("<"); err != nil {
+		return err
+	}
+	keyType := p.next()
+	if err := p.expect(","); err != nil {
+		return err
+	}
+	valueType := p.next()
+	if err := p.expect(">"); err != nil {
+		return err
+	}
+	name := p.next()
+	if err := p.expect("="); err != nil {
+		return err
+	}
+	number, err := strconv.ParseUint(p.next(), 0, 32)
+	if err != nil {
+		return fmt.Errorf("invalid number of field %s", name)
+	}
+	p.skipOptions()
+	if err := p.expect(";"); err != nil {
+		return err
+	}
+
+	// The entry type is named after the field, e.g. "LabelsEntry" for "labels"
+	jsonName := protoJSONName(name)
+	if jsonName == "" {
+		return fmt.Errorf("invalid name of map field %q", name)
+	}
+	first, size := utf8.DecodeRuneInString(jsonName)
+	entryName := protoFullName(message.name, string(unicode.ToUpper(first))+jsonName[size:]+"Entry")
+	entry := p.schema.newProtoMessageType(entryName)
+	entry.mapEntry = true
+	for i, typeName := range []string{keyType, valueType} {
+		field := &protoField{name: []string{"key", "value"}[i], jsonName: []string{"key", "value"}[i]}
+		if kind, ok := protoScalarTypes[typeName]; ok {
+			field.kind = kind
+		} else {
+			p.unresolved = append(p.unresolved, protoUnresolved{field: field, scope: message.name, name: typeName})
+		}
+		entry.fields[uint64(i+1)] = field
+	}
+	message.fields[number] = &protoField{name: name, jsonName: jsonName, kind: protoMessage, repeated: true, typeName: entryName}
+	return nil
+}
+
+// parseEnum reads an enum definition after the "enum" keyword
+func (p *protoParser) parseEnum(scope string) error {
+	name := protoFullName(scope, p.next())
+	if err := p.expect("{"); err != nil {
+		return err
+	}
+	values := map[int64]string{}
+	p.schema.enums[name] = values
+	for {
+		switch token := p.next(); token {
+		case "":
+			return fmt.Errorf("unterminated enum %s", name)
+		case "}":
+			return nil
+		case "option", "reserved":
+			p.skipStatement()
+		case ";":
+		default:
+			if err := p.expect("="); err != nil {
+				return err
+			}
+			number, err := strconv.ParseInt(p.next(), 0, 32)
+			if err != nil {
+				return fmt.Errorf("invalid value of %s", token)
+			}
+			p.skipOptions()
+			if err := p.expect(";"); err != nil {
+				return err
+			}
+			if _, exists := values[number]; !exists {
+				values[number] = token
+			}
+		}
+	}
+}
+
+// resolve looks up the types of message and enum fields, searching from the
+// innermost scope outwards as protoc does. Unknown types stay messages without schema.
+func (p *protoParser) resolve() {
+	for _, unresolved := range p.unresolved {
+		unresolved.field.kind = protoMessage
+		name := unresolved.name
+		var candidates []string
+		if strings.HasPrefix(name, ".") {
+			candidates = []string{name[1:]}
+		} else {
+			for scope := unresolved.scope; ; {
+				candidates = append(candidates, protoFullName(scope, name))
+				if scope == "" {
+					break
+				}
+				if dot := strings.LastIndexByte(scope, '.'); dot >= 0 {
+					scope = scope[:dot]
+				} else {
+					scope = ""
+				}
+			}
+		}
+		for _, candidate := range candidates {
+			if _, ok := p.schema.messages[candidate]; ok {
+				unresolved.field.typeName = candidate
+				break
+			}
+			if _, ok := p.schema.enums[candidate]; ok {
+				unresolved.field.kind = protoEnum
+				unresolved.field.typeName = candidate
+				break
+			}
+		}
+	}
+}
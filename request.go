@@ -1,159 +1,360 @@
 package main
 
 import (
+	"context"
 	"crypto/tls"
 	"crypto/x509"
+	"errors"
 	"fmt"
 	"io"
-	"maps"
 	"net/http"
-	"net/url"
 	"os"
-	"slices"
 	"strings"
+	"sync/atomic"
 	"time"
 )
 
-type Params map[string]string
-
-func (p Params) Format() string {
-	var builder strings.Builder
-	for _, key := range slices.Sorted(maps.Keys(p)) {
-		builder.WriteString(key)
-		builder.WriteString(": ")
-		builder.WriteString(p[key])
-		builder.WriteString("\r\n")
-	}
-	return builder.String()
-}
-
-func ParseParams(input string) Params {
-	params := make(Params)
-	lines := strings.SplitSeq(input, "\r\n")
-	for line := range lines {
-		line = strings.TrimSpace(line)
-		if line == "" {
-			continue
-		}
-		parts := strings.SplitN(line, ":", 2)
-		if len(parts) == 2 {
-			name := strings.TrimSpace(parts[0])
-			value := strings.TrimSpace(parts[1])
-			params[name] = value
-		}
-	}
-	return params
-}
-
 // Request represents a single HTTP request configuration
 type Request struct {
-	Name        string `json:"name"`
-	Method      string `json:"method"`
-	Host        string `json:"host"`
-	Headers     Params `json:"headers"`
-	QueryParams Params `json:"queryParams"`
-	Body        string `json:"body"`
+	ID            string          `json:"id,omitempty"` // Stable identifier, e.g. of the response history
+	Name          string          `json:"name"`
+	Kind          RequestKind     `json:"kind,omitempty"` // Protocol of the request, HTTP if empty
+	Method        string          `json:"method"`
+	Host          string          `json:"host"`
+	Headers       Params          `json:"headers"`
+	QueryParams   Params          `json:"queryParams"`
+	QueryEncoding QueryEncoding   `json:"queryEncoding,omitempty"` // How query parameters are encoded
+	Body          string          `json:"body"`
+	BodyMode      BodyMode        `json:"bodyMode,omitempty"`   // How the body is built
+	FormFields    []FormField     `json:"formFields,omitempty"` // Fields for the form and multipart body modes
+	BodyFile      string          `json:"bodyFile,omitempty"`   // File for the binary file body mode, relative to the project file
+	Chunked       bool            `json:"chunked,omitempty"`    // Send the body with chunked transfer encoding
+	GraphQL       *GraphQLBody    `json:"graphql,omitempty"`    // Query and variables for the GraphQL body mode
+	Streaming     bool            `json:"streaming,omitempty"`  // Stream any response chunk by chunk, not only event streams and NDJSON
+	Charset       string          `json:"charset,omitempty"`    // Decode responses with this charset instead of detecting it
+	Formatter     string          `json:"formatter,omitempty"`  // Show responses with this formatter instead of choosing by content type
+	Protobuf      *ProtobufConfig `json:"protobuf,omitempty"`   // Schema of protobuf responses
+	Auth          *AuthConfig     `json:"auth,omitempty"`
+	Snapshot      *SnapshotConfig `json:"snapshot,omitempty"` // Compare responses with the approved snapshot
+	// Script run after a WebSocket request connected
+	WebSocketSteps []WebSocketStep `json:"webSocketSteps,omitempty"`
+
+	digest *digestSession // Last Digest challenge, reused for following sends
 }
 
 // NewRequest creates a new request with default values
 func (project *Project) NewRequest() *Request {
 	return &Request{
+		ID:          newRequestID(),
 		Name:        "Request",
 		Host:        project.getDefaultHost(),
 		Method:      "GET",
-		Headers:     map[string]string{"Content-Type": "application/json", "Accept": "application/json"},
-		QueryParams: make(map[string]string),
+		Headers:     Params{{Name: "Content-Type", Value: "application/json"}, {Name: "Accept", Value: "application/json"}},
+		QueryParams: Params{},
 		Body:        "",
 	}
 }
 
-func (request *Request) sendRequest(settings *Settings, path string, timeoutInMs int64, callback func(responseData *ResponseData, err error)) {
+// SendOptions holds everything a send needs besides the request itself
+type SendOptions struct {
+	Settings    *Settings      // Global settings (TLS configuration)
+	Path        string         // URL path appended to the request host
+	TimeoutInMs int64          // Request timeout in milliseconds
+	Jar         http.CookieJar // Cookie jar of the project environment, nil to ignore cookies
+	Environment *Environment   // Environment matching the request host, nil for a custom host
+	// Open a new connection instead of reusing a kept-alive one, e.g. for cold timings
+	FreshConnection bool
+	BaseDir         string // Directory relative file paths are resolved against (the project directory)
+	// Called from the sending goroutine while the request body is uploaded; total is -1 if unknown
+	OnUploadProgress func(sent, total int64)
+	// Called from the sending goroutine for every event or chunk of a streamed response
+	OnStreamEvent func(event StreamEvent)
+}
+
+// ErrCancelled is reported when a send was cancelled through its context
+var ErrCancelled = errors.New("request cancelled")
+
+// startSend sends the request in the background and returns a function that cancels it
+func (request *Request) startSend(options SendOptions, callback func(responseData *ResponseData, err error)) context.CancelFunc {
+	ctx, cancel := context.WithCancel(context.Background())
+	go func() {
+		defer cancel()
+		request.sendRequest(ctx, options, callback)
+	}()
+	return cancel
+}
+
+// sendRequest sends the request and calls callback with the response.
+// Cancelling ctx aborts the send with ErrCancelled, or ends a streamed response
+// with the events received so far.
+func (request *Request) sendRequest(ctx context.Context, options SendOptions, callback func(responseData *ResponseData, err error)) {
+	if request.Kind == KindWebSocket {
+		callback(request.runWebSocket(ctx, options))
+		return
+	}
 	startTime := time.Now()
 
-	var requestUrl string
-	if len(request.QueryParams) == 0 {
-		requestUrl = request.Host + path
-	} else {
-		v := url.Values{}
-		for key, value := range request.QueryParams {
-			v.Set(key, value)
+	// The timeout covers the whole exchange, except for streamed responses which
+	// run until the server ends them or they are cancelled
+	parent := ctx
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	timeout := time.Duration(options.TimeoutInMs) * time.Millisecond
+	var timedOut atomic.Bool
+	timer := time.AfterFunc(timeout, func() {
+		timedOut.Store(true)
+		cancel()
+	})
+	defer timer.Stop()
+	sendError := func(err error) error {
+		switch {
+		case parent.Err() != nil:
+			return ErrCancelled
+		case timedOut.Load():
+			return fmt.Errorf("request timed out after %v", timeout)
 		}
-		requestUrl = request.Host + path + "?" + v.Encode()
+		return err
 	}
-	// Create request
-	var reqBody io.Reader
-	if request.Method == "POST" || request.Method == "PUT" || request.Method == "PATCH" {
-		reqBody = strings.NewReader(request.Body)
+
+	requestUrl := request.Host + options.Path
+	if query := request.QueryParams.EncodeQuery(request.QueryEncoding); query != "" {
+		separator := "?"
+		if strings.Contains(requestUrl, "?") {
+			separator = "&"
+		}
+		requestUrl += separator + query
 	}
 
-	req, err := http.NewRequest(request.Method, requestUrl, reqBody)
-	if err != nil {
-		callback(nil, err)
-		return
+	// GraphQL operations are always POSTed as JSON envelope
+	method := request.Method
+	if request.BodyMode == BodyGraphQL {
+		method = "POST"
 	}
 
-	// Add headers from the map
-	for name, value := range request.Headers {
-		name = strings.TrimSpace(name)
-		value = strings.TrimSpace(value)
-		if name != "" {
-			req.Header.Set(name, value)
+	// Create request; the body reader is recreated for every round trip
+	var body *bodySource
+	if method == "POST" || method == "PUT" || method == "PATCH" {
+		var err error
+		if body, err = request.bodySource(options.BaseDir); err != nil {
+			callback(nil, err)
+			return
+		}
+		if options.OnUploadProgress != nil {
+			body = body.withProgress(options.OnUploadProgress)
+		}
+	}
+	newHTTPRequest := func() (*http.Request, error) {
+		req, err := http.NewRequestWithContext(ctx, method, requestUrl, nil)
+		if err != nil {
+			return nil, err
+		}
+		if body != nil && body.length != 0 {
+			if req.Body, err = body.open(); err != nil {
+				return nil, err
+			}
+			req.GetBody = body.open
+			req.ContentLength = body.length
+			if request.Chunked {
+				// An unknown length makes the transport use chunked transfer encoding
+				req.ContentLength = -1
+			}
+		}
+
+		// Add headers in their configured order; repeated names send every value
+		for _, header := range request.Headers {
+			name := strings.TrimSpace(header.Name)
+			if name != "" && !header.Disabled {
+				req.Header.Add(name, strings.TrimSpace(header.Value))
+			}
+		}
+		// Generated bodies bring their own content type, e.g. with the multipart boundary
+		if body != nil && body.contentType != "" {
+			req.Header.Set("Content-Type", body.contentType)
+		} else if body != nil && body.fallbackType != "" && req.Header.Get("Content-Type") == "" {
+			req.Header.Set("Content-Type", body.fallbackType)
 		}
+		return req, nil
 	}
 
-	// Create HTTP client with TLS configuration and timeout
-	client, err := createHTTPClient(settings, timeoutInMs)
+	// Get HTTP client with TLS configuration; the timeout is enforced through the context
+	client, err := options.Settings.httpClient(options.Environment, 0, options.Jar, options.FreshConnection)
 	if err != nil {
 		callback(nil, err)
 		return
 	}
 
-	resp, err := client.Do(req)
-	duration := time.Since(startTime)
-
+	resp, roundTrips, err := request.do(client, newHTTPRequest)
 	if err != nil {
-		callback(nil, err)
+		callback(nil, sendError(err))
 		return
 	}
 	defer resp.Body.Close()
 
-	// Read response
-	respBody, err := io.ReadAll(resp.Body)
+	// Read response, event by event for streams
+	transferStart := time.Now()
+	var respBody *responseBody
+	var events []StreamEvent
+	contentType := resp.Header.Get("Content-Type")
+	kind := streamKindOf(contentType, request.Streaming)
+	streamed := kind != streamNone
+	if streamed {
+		timer.Stop()
+		err = readStream(resp.Body, kind, func(event StreamEvent) {
+			events = append(events, event)
+			if options.OnStreamEvent != nil {
+				options.OnStreamEvent(event)
+			}
+		})
+		if parent.Err() != nil {
+			err = nil
+		}
+	} else {
+		respBody, err = readResponseBody(resp.Body, options.Settings.maxResponseMemory())
+	}
 	if err != nil {
-		callback(nil, err)
+		callback(nil, sendError(err))
 		return
 	}
+	duration := time.Since(startTime)
 
-	// Get content type to determine formatting
-	contentType := resp.Header.Get("Content-Type")
-	formattedBody := formatResponse(string(respBody), contentType)
+	// Timing of the round trip that produced the response, completed by the body transfer
+	timing := roundTrips[len(roundTrips)-1].Timing
+	timing.Transfer = time.Since(transferStart)
 
-	// Extract response headers
-	headers := make(map[string]string)
-	for name, values := range resp.Header {
-		if len(values) > 0 {
-			headers[name] = values[0]
-		}
-	}
+	// Extract response headers with all their values
+	headers := ParamsFromHeader(resp.Header)
 
 	// Create response data
 	responseData := &ResponseData{
-		Body:       formattedBody,
-		Headers:    headers,
-		StatusCode: resp.StatusCode,
-		Status:     resp.Status,
-		Duration:   duration,
-		Timestamp:  time.Now(),
+		Headers:       headers,
+		StatusCode:    resp.StatusCode,
+		Status:        resp.Status,
+		Duration:      duration,
+		Timestamp:     time.Now(),
+		RoundTrips:    roundTrips,
+		Timing:        timing,
+		Events:        events,
+		ContentType:   contentType,
+		Encoding:      resp.Header.Get("Content-Encoding"),
+		Decompressed:  resp.Uncompressed,
+		ContentLength: resp.ContentLength,
+		format:        request.responseFormat(contentType, options.Settings, options.BaseDir),
+	}
+	// The raw body is kept as received and formatted when it is displayed.
+	// A stream may end without any event, e.g. when it is stopped right away.
+	if streamed {
+		responseData.Text = formatStreamEvents(events)
+	} else {
+		responseData.Raw = respBody.data
+		if responseData.Raw == nil {
+			responseData.Raw = []byte{}
+		}
+		responseData.Size = respBody.size
+		responseData.Truncated = respBody.file != ""
+		responseData.BodyFile = respBody.file
+	}
+	if resp.Uncompressed {
+		// The transport only decompresses gzip it asked for itself
+		responseData.Encoding = "gzip"
+	}
+	if parent.Err() != nil {
+		responseData.Cancelled = true
+		responseData.Status += " (cancelled)"
+	}
+	// GraphQL reports errors in the body of successful responses
+	if request.BodyMode == BodyGraphQL && resp.StatusCode >= 200 && resp.StatusCode <= 299 {
+		responseData.Failures = graphQLFailures(responseData.content())
 	}
 
 	// Update UI
 	callback(responseData, nil)
 }
 
+// responseFormat returns how responses of the request with the content type are displayed.
+// Protobuf responses are decoded with the schema attached to the request.
+func (request *Request) responseFormat(contentType string, settings *Settings, baseDir string) formatOptions {
+	format := formatOptions{
+		jsonIndent: settings.jsonIndent(),
+		charset:    request.Charset,
+		formatter:  request.Formatter,
+	}
+	if formatter := chosenFormatter(contentType, format); formatter != nil && formatter.Name == "protobuf" &&
+		request.Protobuf != nil && request.Protobuf.SchemaFile != "" {
+		format.protoSchema, format.protoErr = loadProtoSchema(resolvePath(baseDir, request.Protobuf.SchemaFile))
+		if format.protoErr == nil {
+			format.protoMessage, format.protoErr = format.protoSchema.message(request.Protobuf.Message)
+		}
+	}
+	return format
+}
+
+// do executes the request, adds a freshly minted JWT if configured and
+// transparently answers an HTTP Digest challenge.
+// Every round trip is returned so that an authentication retry stays visible.
+func (request *Request) do(client *http.Client, newHTTPRequest func() (*http.Request, error)) (*http.Response, []RoundTrip, error) {
+	auth := request.Auth
+	useDigest := auth != nil && auth.Type == AuthDigest
+	var digest *digestSession
+	if useDigest {
+		digest = request.digestSession()
+	}
+
+	var roundTrips []RoundTrip
+	for attempt := 0; ; attempt++ {
+		req, err := newHTTPRequest()
+		if err != nil {
+			return nil, roundTrips, err
+		}
+		if auth != nil && auth.Type == AuthJWT && auth.JWT != nil {
+			token, err := auth.JWT.mint(time.Now())
+			if err != nil {
+				return nil, roundTrips, err
+			}
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		if useDigest && digest.hasChallenge() {
+			authorization, err := digest.authorize(req, auth.Username, auth.Password)
+			if err != nil {
+				return nil, roundTrips, err
+			}
+			req.Header.Set("Authorization", authorization)
+		}
+
+		req, trace := traceRequest(req)
+		startTime := time.Now()
+		resp, err := client.Do(req)
+		if err != nil {
+			return nil, roundTrips, err
+		}
+		roundTrips = append(roundTrips, RoundTrip{Status: resp.Status, Duration: time.Since(startTime), Timing: trace.result()})
+
+		// Retry once with fresh credentials, or twice if the remembered nonce turned out to be stale
+		if !useDigest || resp.StatusCode != http.StatusUnauthorized || attempt >= 2 {
+			return resp, roundTrips, nil
+		}
+		challenge, err := parseDigestChallenge(resp.Header)
+		if err != nil || (attempt == 1 && !challenge.Stale) {
+			return resp, roundTrips, nil
+		}
+		io.Copy(io.Discard, resp.Body)
+		resp.Body.Close()
+		digest.update(challenge)
+	}
+}
+
 // createHTTPClient creates an HTTP client with optional TLS client certificate
-func createHTTPClient(settings *Settings, timeoutInMs int64) (*http.Client, error) {
+// and the proxy configured for the environment
+func createHTTPClient(settings *Settings, env *Environment, timeoutInMs int64) (*http.Client, error) {
+	// Start from the default transport to keep its dial and idle connection timeouts
+	transport := http.DefaultTransport.(*http.Transport).Clone()
 	client := &http.Client{
-		Timeout: time.Duration(timeoutInMs) * time.Millisecond,
+		Timeout:   time.Duration(timeoutInMs) * time.Millisecond,
+		Transport: transport,
+	}
+
+	proxy := effectiveProxy(settings, env)
+	if err := proxy.apply(transport); err != nil {
+		return nil, err
 	}
 
 	cert := &settings.Certificate
@@ -161,7 +362,7 @@ func createHTTPClient(settings *Settings, timeoutInMs int64) (*http.Client, erro
 	keyFile := strings.TrimSpace(cert.KeyFile)
 
 	// No certificate configured
-	if certFile == "" && keyFile == "" && !cert.SkipVerify {
+	if certFile == "" && keyFile == "" && cert.CACertFile == "" && !cert.SkipVerify {
 		return client, nil
 	}
 
@@ -194,11 +395,6 @@ func createHTTPClient(settings *Settings, timeoutInMs int64) (*http.Client, erro
 	// Skip TLS verification if requested
 	tlsConfig.InsecureSkipVerify = cert.SkipVerify
 
-	// Create transport with TLS config
-	transport := &http.Transport{
-		TLSClientConfig: tlsConfig,
-	}
-
-	client.Transport = transport
+	transport.TLSClientConfig = tlsConfig
 	return client, nil
 }
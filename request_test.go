@@ -0,0 +1,28 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestSendEmptyStream(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/event-stream")
+	}))
+	defer server.Close()
+
+	request := &Request{Method: "GET", Host: server.URL, Headers: Params{}, QueryParams: Params{}}
+	var response *ResponseData
+	var err error
+	request.sendRequest(context.Background(), SendOptions{Settings: &Settings{}, TimeoutInMs: 5000}, func(responseData *ResponseData, sendErr error) {
+		response, err = responseData, sendErr
+	})
+	if err != nil {
+		t.Fatalf("sending: %v", err)
+	}
+	if response.StatusCode != http.StatusOK || len(response.Events) != 0 || response.Text != "" || response.Raw != nil {
+		t.Errorf("empty stream gave status %d, %d events, text %q, body %q", response.StatusCode, len(response.Events), response.Text, response.Raw)
+	}
+}
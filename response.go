@@ -1,54 +1,150 @@
 package main
 
 import (
-	"encoding/json"
+	"bytes"
+	"compress/gzip"
+	"compress/zlib"
 	"encoding/xml"
+	"fmt"
 	"io"
 	"strings"
 )
 
-// formatResponse formats the response body based on content type
-func formatResponse(body string, contentType string) string {
-	contentType = strings.ToLower(contentType)
+// maxDecodedView limits how much of a compressed body is decompressed for display
+const maxDecodedView = 64 << 20
 
-	// Try JSON formatting
-	if strings.Contains(contentType, "json") || strings.HasPrefix(strings.TrimSpace(body), "{") || strings.HasPrefix(strings.TrimSpace(body), "[") {
-		if formatted, ok := formatJSON(body); ok {
-			return formatted
-		}
+// responseView is the display form of a response body
+type responseView struct {
+	text    string
+	binary  bool
+	charset string // Charset a text body was decoded with
+	source  []byte // Decoded body filter expressions are applied to, nil for hex dumps
+}
+
+// Body returns the display text of the response; the raw body is formatted on first use
+func (responseData *ResponseData) Body() string {
+	if responseData.Raw == nil {
+		return responseData.Text
 	}
+	return responseData.formatted().text
+}
 
-	// Try XML formatting
-	if strings.Contains(contentType, "xml") || strings.HasPrefix(strings.TrimSpace(body), "<") {
-		if formatted, ok := formatXML(body); ok {
-			return formatted
+// FilteredBody returns the display text narrowed by the filter expression of the response.
+// A filter that cannot be applied is explained in place of the body.
+func (responseData *ResponseData) FilteredBody() string {
+	if strings.TrimSpace(responseData.Filter) == "" {
+		return responseData.Body()
+	}
+	source := []byte(responseData.Text)
+	if responseData.Raw != nil {
+		view := responseData.formatted()
+		if view.source == nil {
+			return "Filter error: the body is binary\r\n\r\n" + view.text
 		}
+		source = view.source
+	}
+	filtered, err := filterJSON(source, responseData.Filter, responseData.format.jsonIndent)
+	if err != nil {
+		return fmt.Sprintf("Filter error: %v", err)
 	}
+	return filtered
+}
 
-	// Plain text - just return as-is with Windows line endings
-	return strings.ReplaceAll(body, "\n", "\r\n")
+// IsBinary reports whether the body is binary data, shown as hex dump
+func (responseData *ResponseData) IsBinary() bool {
+	return responseData.Raw != nil && responseData.formatted().binary
 }
 
-// formatJSON pretty-prints JSON
-func formatJSON(input string) (string, bool) {
-	var data any
-	if err := json.Unmarshal([]byte(input), &data); err != nil {
-		return "", false
+// Charset returns the charset the body is shown with, empty for binary bodies
+func (responseData *ResponseData) Charset() string {
+	if responseData.Raw == nil {
+		return ""
 	}
+	return responseData.formatted().charset
+}
 
-	formatted, err := json.MarshalIndent(data, "", "  ")
-	if err != nil {
-		return "", false
+// formatted formats the body once. Binary serialization formats are decoded,
+// other binary bodies are previewed as hex dump and the beginning of a truncated
+// body is shown as it is.
+func (responseData *ResponseData) formatted() *responseView {
+	if responseData.view != nil {
+		return responseData.view
 	}
+	data := responseData.content()
+	view := &responseView{}
+	options := responseData.format
+	if formatter := chosenFormatter(responseData.ContentType, options); formatter != nil && formatter.Decode != nil && !responseData.Truncated {
+		if decoded, ok := formatter.Decode(data, options); ok {
+			view.binary = true
+			view.text = decoded
+			view.source = []byte(decoded)
+			if options.protoErr != nil && formatter.Name == "protobuf" {
+				view.text = fmt.Sprintf("%v, fields are shown by number\r\n\r\n%s", options.protoErr, decoded)
+			}
+			responseData.view = view
+			return view
+		}
+	}
+	// A charset chosen for the request marks the body as text
+	binary := options.charset == "" && isBinaryBody(responseData.ContentType, data)
+	var text string
+	if !binary {
+		text, view.charset = decodeText(data, responseData.ContentType, options.charset)
+		view.source = []byte(text)
+	}
+	switch {
+	case binary:
+		size := int64(len(data))
+		if responseData.Truncated {
+			size = responseData.Size
+		}
+		view.binary = true
+		view.text = hexPreview(data, size, responseData.ContentType)
+	case responseData.Truncated:
+		view.text = truncationNote(int64(len(data)), responseData.Size) + strings.ReplaceAll(text, "\n", "\r\n")
+	default:
+		view.text = formatResponse(text, responseData.ContentType, options)
+	}
+	responseData.view = view
+	return view
+}
 
-	// Convert to Windows line endings
-	return strings.ReplaceAll(string(formatted), "\n", "\r\n"), true
+// content returns the body with its Content-Encoding removed.
+// Bodies that cannot be decompressed, e.g. Brotli, are returned as received.
+func (responseData *ResponseData) content() []byte {
+	if responseData.Decompressed {
+		return responseData.Raw
+	}
+	var reader io.Reader
+	var err error
+	switch strings.ToLower(strings.TrimSpace(responseData.Encoding)) {
+	case "gzip", "x-gzip":
+		reader, err = gzip.NewReader(bytes.NewReader(responseData.Raw))
+	case "deflate":
+		// HTTP deflate is a zlib stream
+		reader, err = zlib.NewReader(bytes.NewReader(responseData.Raw))
+	default:
+		return responseData.Raw
+	}
+	if err != nil {
+		return responseData.Raw
+	}
+	// A truncated body decompresses up to where it was cut off
+	decoded, err := io.ReadAll(io.LimitReader(reader, maxDecodedView))
+	if err != nil && len(decoded) == 0 {
+		return responseData.Raw
+	}
+	return decoded
 }
 
 // formatXML pretty-prints XML
 func formatXML(input string) (string, bool) {
 	var buf strings.Builder
 	decoder := xml.NewDecoder(strings.NewReader(input))
+	// The input is already decoded, whatever encoding the prolog declares
+	decoder.CharsetReader = func(charset string, input io.Reader) (io.Reader, error) {
+		return input, nil
+	}
 	encoder := xml.NewEncoder(&buf)
 	encoder.Indent("", "  ")
 
@@ -69,6 +165,5 @@ func formatXML(input string) (string, bool) {
 		return "", false
 	}
 
-	// Convert to Windows line endings
-	return strings.ReplaceAll(buf.String(), "\n", "\r\n"), true
+	return windowsLineEndings(buf.String()), true
 }
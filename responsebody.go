@@ -0,0 +1,153 @@
+package main
+
+import (
+	"bytes"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"os"
+	"strings"
+)
+
+// defaultMaxResponseMemory is the part of a response body kept in memory unless configured otherwise
+const defaultMaxResponseMemory = 10 << 20
+
+// hexPreviewSize is the part of a binary body shown as hex dump
+const hexPreviewSize = 64 << 10
+
+// maxResponseMemory returns the number of body bytes kept in memory, larger bodies are spilled to a temporary file
+func (settings *Settings) maxResponseMemory() int64 {
+	if settings == nil || settings.MaxResponseMemoryMB <= 0 {
+		return defaultMaxResponseMemory
+	}
+	return int64(settings.MaxResponseMemoryMB) << 20
+}
+
+// responseBody is a response body read up to a memory cap
+type responseBody struct {
+	data []byte // The complete body, or its first limit bytes if it was spilled
+	size int64  // Size of the complete body
+	file string // Temporary file holding the complete body, empty if it fit into memory
+}
+
+// readResponseBody reads body, keeping at most limit bytes in memory.
+// Once the limit is exceeded the complete body is written to a temporary file.
+func readResponseBody(body io.Reader, limit int64) (*responseBody, error) {
+	var buffer bytes.Buffer
+	n, err := buffer.ReadFrom(io.LimitReader(body, limit+1))
+	if err != nil {
+		return nil, err
+	}
+	if n <= limit {
+		return &responseBody{data: buffer.Bytes(), size: n}, nil
+	}
+
+	file, err := os.CreateTemp("", "resttester-response-*")
+	if err != nil {
+		return nil, fmt.Errorf("error creating file for large response: %v", err)
+	}
+	defer file.Close()
+	fail := func(err error) (*responseBody, error) {
+		file.Close()
+		os.Remove(file.Name())
+		return nil, err
+	}
+	if _, err := file.Write(buffer.Bytes()); err != nil {
+		return fail(fmt.Errorf("error writing large response: %v", err))
+	}
+	rest, err := io.Copy(file, body)
+	if err != nil {
+		return fail(err)
+	}
+	return &responseBody{data: buffer.Bytes()[:limit], size: n + rest, file: file.Name()}, nil
+}
+
+// isBinaryBody decides whether a body is binary data rather than text, from its
+// content type or, if that is unspecific, by sniffing its first bytes
+func isBinaryBody(contentType string, data []byte) bool {
+	mediaType, _, _ := mime.ParseMediaType(contentType)
+	mediaType = strings.ToLower(mediaType)
+	switch {
+	case strings.HasPrefix(mediaType, "text/"),
+		strings.Contains(mediaType, "json"),
+		strings.Contains(mediaType, "xml"),
+		strings.Contains(mediaType, "javascript"),
+		strings.Contains(mediaType, "yaml"),
+		mediaType == "application/x-www-form-urlencoded",
+		mediaType == "application/graphql":
+		return false
+	case strings.HasPrefix(mediaType, "image/") && mediaType != "image/svg+xml",
+		strings.HasPrefix(mediaType, "audio/"),
+		strings.HasPrefix(mediaType, "video/"),
+		strings.HasPrefix(mediaType, "font/"),
+		mediaType == "application/pdf",
+		mediaType == "application/zip",
+		mediaType == "application/gzip",
+		mediaType == "application/x-protobuf",
+		mediaType == "application/protobuf",
+		mediaType == "application/msgpack",
+		mediaType == "application/x-msgpack",
+		mediaType == "application/cbor":
+		return true
+	}
+	if len(data) == 0 {
+		return false
+	}
+	// Sniffing reports text unless control characters other than whitespace occur
+	sniffed := http.DetectContentType(data)
+	return !strings.HasPrefix(sniffed, "text/") && !strings.Contains(sniffed, "json")
+}
+
+// hexPreview renders the start of a binary body as hex dump with Windows line endings
+func hexPreview(data []byte, size int64, contentType string) string {
+	var builder strings.Builder
+	description := "Binary body"
+	if contentType != "" {
+		description += " (" + contentType + ")"
+	}
+	fmt.Fprintf(&builder, "%s, %s", description, formatSize(size))
+	if int64(len(data)) > hexPreviewSize || int64(len(data)) < size {
+		fmt.Fprintf(&builder, ", showing the first %s. Use 'Save Body' to get all of it.", formatSize(min(int64(len(data)), hexPreviewSize)))
+		data = data[:min(len(data), hexPreviewSize)]
+	}
+	builder.WriteString("\r\n\r\n")
+	builder.WriteString(strings.ReplaceAll(hex.Dump(data), "\n", "\r\n"))
+	return builder.String()
+}
+
+// truncationNote introduces the shown beginning of a body that exceeded the memory cap
+func truncationNote(shown, size int64) string {
+	return fmt.Sprintf("Body truncated: showing the first %s of %s. Use 'Save Body' to get all of it.\r\n\r\n", formatSize(shown), formatSize(size))
+}
+
+// saveBody writes the complete response body to path
+func (responseData *ResponseData) saveBody(path string) error {
+	if responseData.BodyFile == "" {
+		return os.WriteFile(path, responseData.Raw, 0o644)
+	}
+	source, err := os.Open(responseData.BodyFile)
+	if err != nil {
+		return fmt.Errorf("response body is no longer available: %v", err)
+	}
+	defer source.Close()
+	target, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(target, source); err != nil {
+		target.Close()
+		return err
+	}
+	return target.Close()
+}
+
+// removeBodyFiles deletes the temporary files of spilled response bodies
+func removeBodyFiles(responses []ResponseData) {
+	for _, response := range responses {
+		if response.BodyFile != "" {
+			os.Remove(response.BodyFile)
+		}
+	}
+}
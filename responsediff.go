@@ -0,0 +1,335 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+)
+
+// diffContext is the number of unchanged lines shown around changed body lines
+const diffContext = 3
+
+// maxLineDiffCells limits the lines compared by the line diff, longer bodies differing
+// in many lines are shown as completely replaced
+const maxLineDiffCells = 4 << 20
+
+// DiffKind tells how an entry differs
+type DiffKind byte
+
+const (
+	DiffAdded   DiffKind = '+'
+	DiffRemoved DiffKind = '-'
+	DiffChanged DiffKind = '~'
+)
+
+// DiffEntry is a difference of the status, a header or a JSON value
+type DiffEntry struct {
+	Kind DiffKind
+	Path string // Header name or JSON path like .items[0].id
+	Old  string // Value in the older response, empty if added
+	New  string // Value in the newer response, empty if removed
+}
+
+// ResponseDiff is the difference between two responses
+type ResponseDiff struct {
+	Old, New string // Labels of the compared responses
+	Status   []DiffEntry
+	Headers  []DiffEntry
+	JSON     bool        // Both bodies are JSON and were compared by value
+	Body     []DiffEntry // Changed JSON values by path
+	Lines    []string    // Unified diff of the body lines of other bodies
+	Note     string      // Summary of bodies that cannot be compared by line, e.g. binary ones
+}
+
+// Equal reports whether the responses have the same status, headers and body
+func (diff ResponseDiff) Equal() bool {
+	return len(diff.Status) == 0 && len(diff.Headers) == 0 && len(diff.Body) == 0 && len(diff.Lines) == 0 && diff.Note == ""
+}
+
+// diffResponses compares the status, headers and body of two responses. JSON bodies are
+// compared by value regardless of key order, other bodies line by line.
+func diffResponses(old, new *ResponseData, oldLabel, newLabel string) ResponseDiff {
+	diff := ResponseDiff{Old: oldLabel, New: newLabel}
+	if old.Status != new.Status {
+		diff.Status = []DiffEntry{{Kind: DiffChanged, Path: "Status", Old: old.Status, New: new.Status}}
+	}
+	diff.Headers = diffHeaders(old.Headers, new.Headers)
+
+	oldSource, oldText := old.diffSource()
+	newSource, newText := new.diffSource()
+	if !oldText || !newText {
+		if !bytes.Equal(oldSource, newSource) {
+			diff.Note = fmt.Sprintf("Bodies differ: %s → %s", formatSize(int64(len(oldSource))), formatSize(int64(len(newSource))))
+		}
+		return diff
+	}
+	if oldValue, ok := parseJSONDocument(oldSource); ok {
+		if newValue, ok := parseJSONDocument(newSource); ok {
+			diff.JSON = true
+			diff.Body = diffJSON("", oldValue, newValue, nil)
+			return diff
+		}
+	}
+	diff.Lines = unifiedDiff(diffLines(strings.Split(old.Body(), "\r\n"), strings.Split(new.Body(), "\r\n")), diffContext)
+	return diff
+}
+
+// diffSource returns the decoded body and whether it is text. Binary bodies,
+// e.g. images, are returned as received.
+func (responseData *ResponseData) diffSource() ([]byte, bool) {
+	if responseData.Raw == nil {
+		return []byte(responseData.Text), true
+	}
+	if source := responseData.formatted().source; source != nil {
+		return source, true
+	}
+	return responseData.content(), false
+}
+
+// parseJSONDocument parses a JSON body; a sequence like NDJSON becomes an array of its records
+func parseJSONDocument(data []byte) (any, bool) {
+	if len(bytes.TrimSpace(data)) == 0 {
+		return nil, false
+	}
+	values, err := parseJSONValues(data)
+	if err != nil {
+		return nil, false
+	}
+	if len(values) == 1 {
+		return values[0], true
+	}
+	return values, true
+}
+
+// diffHeaders compares headers by name, ignoring case; repeated headers are compared as a list
+func diffHeaders(old, new Params) []DiffEntry {
+	var names []string
+	seen := map[string]bool{}
+	for _, param := range append(append(Params{}, old...), new...) {
+		if key := strings.ToLower(param.Name); !seen[key] {
+			seen[key] = true
+			names = append(names, param.Name)
+		}
+	}
+	var entries []DiffEntry
+	for _, name := range names {
+		oldValues, newValues := old.Values(name), new.Values(name)
+		oldValue, newValue := strings.Join(oldValues, ", "), strings.Join(newValues, ", ")
+		switch {
+		case oldValues == nil:
+			entries = append(entries, DiffEntry{Kind: DiffAdded, Path: name, New: newValue})
+		case newValues == nil:
+			entries = append(entries, DiffEntry{Kind: DiffRemoved, Path: name, Old: oldValue})
+		case oldValue != newValue:
+			entries = append(entries, DiffEntry{Kind: DiffChanged, Path: name, Old: oldValue, New: newValue})
+		}
+	}
+	return entries
+}
+
+// diffJSON appends the differences of two JSON values. Objects are compared by key,
+// arrays by index; numbers are equal if their values are, e.g. 1 and 1.0.
+func diffJSON(path string, old, new any, entries []DiffEntry) []DiffEntry {
+	oldObject, oldIsObject := old.(*jsonObject)
+	newObject, newIsObject := new.(*jsonObject)
+	if oldIsObject && newIsObject {
+		for _, key := range oldObject.keys {
+			if value, ok := newObject.values[key]; ok {
+				entries = diffJSON(path+jsonPathKey(key), oldObject.values[key], value, entries)
+			} else {
+				entries = append(entries, DiffEntry{Kind: DiffRemoved, Path: path + jsonPathKey(key), Old: string(appendJSONValue(nil, oldObject.values[key]))})
+			}
+		}
+		for _, key := range newObject.keys {
+			if _, ok := oldObject.values[key]; !ok {
+				entries = append(entries, DiffEntry{Kind: DiffAdded, Path: path + jsonPathKey(key), New: string(appendJSONValue(nil, newObject.values[key]))})
+			}
+		}
+		return entries
+	}
+	oldArray, oldIsArray := old.([]any)
+	newArray, newIsArray := new.([]any)
+	if oldIsArray && newIsArray {
+		for i := range max(len(oldArray), len(newArray)) {
+			elementPath := fmt.Sprintf("%s[%d]", path, i)
+			switch {
+			case i >= len(newArray):
+				entries = append(entries, DiffEntry{Kind: DiffRemoved, Path: elementPath, Old: string(appendJSONValue(nil, oldArray[i]))})
+			case i >= len(oldArray):
+				entries = append(entries, DiffEntry{Kind: DiffAdded, Path: elementPath, New: string(appendJSONValue(nil, newArray[i]))})
+			default:
+				entries = diffJSON(elementPath, oldArray[i], newArray[i], entries)
+			}
+		}
+		return entries
+	}
+	if jsonRank(old) != jsonRank(new) || jsonCompare(old, new) != 0 {
+		if path == "" {
+			path = "."
+		}
+		entries = append(entries, DiffEntry{Kind: DiffChanged, Path: path, Old: string(appendJSONValue(nil, old)), New: string(appendJSONValue(nil, new))})
+	}
+	return entries
+}
+
+// jsonPathKey returns the path segment of an object key, ".name" or ["other key"]
+func jsonPathKey(key string) string {
+	plain := key != "" && !(key[0] >= '0' && key[0] <= '9')
+	for _, c := range key {
+		if !(c == '_' || (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z') || (c >= '0' && c <= '9')) {
+			plain = false
+			break
+		}
+	}
+	if plain {
+		return "." + key
+	}
+	return "[" + string(appendJSONString(nil, key)) + "]"
+}
+
+// lineOp is a line of a line diff: kept (' '), removed ('-') or added ('+')
+type lineOp struct {
+	kind byte
+	text string
+}
+
+// diffLines computes the changes from old to new lines along their longest common subsequence
+func diffLines(old, new []string) []lineOp {
+	prefix := 0
+	for prefix < len(old) && prefix < len(new) && old[prefix] == new[prefix] {
+		prefix++
+	}
+	suffix := 0
+	for suffix < len(old)-prefix && suffix < len(new)-prefix && old[len(old)-1-suffix] == new[len(new)-1-suffix] {
+		suffix++
+	}
+	var ops []lineOp
+	for _, line := range old[:prefix] {
+		ops = append(ops, lineOp{' ', line})
+	}
+	a, b := old[prefix:len(old)-suffix], new[prefix:len(new)-suffix]
+	i, j := 0, 0
+	if len(a)*len(b) <= maxLineDiffCells {
+		// lengths[i*(len(b)+1)+j] is the length of the common subsequence of a[i:] and b[j:]
+		width := len(b) + 1
+		lengths := make([]int32, (len(a)+1)*width)
+		for i := len(a) - 1; i >= 0; i-- {
+			for j := len(b) - 1; j >= 0; j-- {
+				if a[i] == b[j] {
+					lengths[i*width+j] = lengths[(i+1)*width+j+1] + 1
+				} else {
+					lengths[i*width+j] = max(lengths[(i+1)*width+j], lengths[i*width+j+1])
+				}
+			}
+		}
+		for i < len(a) && j < len(b) {
+			switch {
+			case a[i] == b[j]:
+				ops = append(ops, lineOp{' ', a[i]})
+				i++
+				j++
+			case lengths[(i+1)*width+j] >= lengths[i*width+j+1]:
+				ops = append(ops, lineOp{'-', a[i]})
+				i++
+			default:
+				ops = append(ops, lineOp{'+', b[j]})
+				j++
+			}
+		}
+	}
+	for _, line := range a[i:] {
+		ops = append(ops, lineOp{'-', line})
+	}
+	for _, line := range b[j:] {
+		ops = append(ops, lineOp{'+', line})
+	}
+	for _, line := range old[len(old)-suffix:] {
+		ops = append(ops, lineOp{' ', line})
+	}
+	return ops
+}
+
+// unifiedDiff renders the changed lines in hunks of the unified diff format with
+// the given number of unchanged lines around them. It is empty if nothing changed.
+func unifiedDiff(ops []lineOp, context int) []string {
+	// Line numbers in the old and new text before each op
+	oldLines := make([]int, len(ops)+1)
+	newLines := make([]int, len(ops)+1)
+	for i, op := range ops {
+		oldLines[i+1], newLines[i+1] = oldLines[i], newLines[i]
+		if op.kind != '+' {
+			oldLines[i+1]++
+		}
+		if op.kind != '-' {
+			newLines[i+1]++
+		}
+	}
+	var lines []string
+	for start := 0; start < len(ops); {
+		first := start
+		for first < len(ops) && ops[first].kind == ' ' {
+			first++
+		}
+		if first == len(ops) {
+			break
+		}
+		// Changes closer than twice the context share a hunk
+		last := first
+		for k := first; k < len(ops) && k-last <= 2*context; k++ {
+			if ops[k].kind != ' ' {
+				last = k
+			}
+		}
+		from, to := max(first-context, start), min(last+context+1, len(ops))
+		lines = append(lines, fmt.Sprintf("@@ -%s +%s @@", hunkRange(oldLines[from], oldLines[to]), hunkRange(newLines[from], newLines[to])))
+		for _, op := range ops[from:to] {
+			lines = append(lines, string(op.kind)+op.text)
+		}
+		start = to
+	}
+	return lines
+}
+
+// hunkRange formats the start line and line count of a hunk
+func hunkRange(from, to int) string {
+	if to == from {
+		return fmt.Sprintf("%d,0", from)
+	}
+	return fmt.Sprintf("%d,%d", from+1, to-from)
+}
+
+// String renders the difference as text, which is also how it is exported
+func (diff ResponseDiff) String() string {
+	var lines []string
+	lines = append(lines, "--- "+diff.Old, "+++ "+diff.New)
+	if diff.Equal() {
+		return strings.Join(append(lines, "", "No differences"), "\r\n")
+	}
+	section := func(title string, entries []DiffEntry) {
+		if len(entries) == 0 {
+			return
+		}
+		lines = append(lines, "", title)
+		for _, entry := range entries {
+			switch entry.Kind {
+			case DiffAdded:
+				lines = append(lines, fmt.Sprintf("+ %s: %s", entry.Path, entry.New))
+			case DiffRemoved:
+				lines = append(lines, fmt.Sprintf("- %s: %s", entry.Path, entry.Old))
+			default:
+				lines = append(lines, fmt.Sprintf("~ %s: %s → %s", entry.Path, entry.Old, entry.New))
+			}
+		}
+	}
+	section("Status", diff.Status)
+	section("Headers", diff.Headers)
+	section("Body (JSON)", diff.Body)
+	if len(diff.Lines) > 0 {
+		lines = append(lines, "", "Body")
+		lines = append(lines, diff.Lines...)
+	}
+	if diff.Note != "" {
+		lines = append(lines, "", diff.Note)
+	}
+	return strings.Join(lines, "\r\n")
+}
@@ -0,0 +1,521 @@
+package main
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"math/big"
+	"strconv"
+	"time"
+)
+
+// Binary serialization formats are decoded to compact JSON, which formatJSON then lays out.
+// Byte strings are shown base64 encoded, as in the JSON mappings of the formats.
+
+// maxDecodeDepth limits the nesting of decoded values
+const maxDecodeDepth = 512
+
+var errTruncatedData = errors.New("unexpected end of data")
+
+// appendJSONString appends s as JSON string without escaping HTML characters
+func appendJSONString(dst []byte, s string) []byte {
+	var buffer bytes.Buffer
+	encoder := json.NewEncoder(&buffer)
+	encoder.SetEscapeHTML(false)
+	encoder.Encode(s)
+	return append(dst, bytes.TrimSuffix(buffer.Bytes(), []byte("\n"))...)
+}
+
+// appendJSONFloat appends a float; NaN and infinities, which JSON cannot represent, become strings
+func appendJSONFloat(dst []byte, f float64, bits int) []byte {
+	if math.IsNaN(f) || math.IsInf(f, 0) {
+		return appendJSONString(dst, strconv.FormatFloat(f, 'g', -1, bits))
+	}
+	return strconv.AppendFloat(dst, f, 'g', -1, bits)
+}
+
+// appendJSONBytes appends a byte string as base64 encoded JSON string
+func appendJSONBytes(dst []byte, data []byte) []byte {
+	return appendJSONString(dst, base64.StdEncoding.EncodeToString(data))
+}
+
+// decodeSequence decodes consecutive values until the data ends.
+// A single value is returned as is, several values as lines of a sequence.
+func decodeSequence(data []byte, decode func(d *serialDecoder) error, options formatOptions) (string, bool) {
+	if len(data) == 0 {
+		return "", false
+	}
+	var records []string
+	decoder := &serialDecoder{data: data}
+	for decoder.pos < len(decoder.data) {
+		decoder.out = decoder.out[:0]
+		if err := decode(decoder); err != nil {
+			return "", false
+		}
+		formatted, ok := formatJSON(decoder.out, options.jsonIndent)
+		if !ok {
+			return "", false
+		}
+		records = append(records, formatted)
+	}
+	return joinRecords(records), true
+}
+
+// joinRecords separates the records of a sequence by blank lines
+func joinRecords(records []string) string {
+	var builder bytes.Buffer
+	for i, record := range records {
+		if i > 0 {
+			builder.WriteString("\r\n\r\n")
+		}
+		builder.WriteString(record)
+	}
+	return builder.String()
+}
+
+// serialDecoder reads a binary encoded value and writes it as compact JSON to out
+type serialDecoder struct {
+	data  []byte
+	pos   int
+	out   []byte
+	depth int
+}
+
+// next returns the next n bytes
+func (d *serialDecoder) next(n uint64) ([]byte, error) {
+	if n > uint64(len(d.data)-d.pos) {
+		return nil, errTruncatedData
+	}
+	b := d.data[d.pos : d.pos+int(n)]
+	d.pos += int(n)
+	return b, nil
+}
+
+// uint reads a big endian unsigned integer of size bytes
+func (d *serialDecoder) uint(size int) (uint64, error) {
+	b, err := d.next(uint64(size))
+	if err != nil {
+		return 0, err
+	}
+	switch size {
+	case 1:
+		return uint64(b[0]), nil
+	case 2:
+		return uint64(binary.BigEndian.Uint16(b)), nil
+	case 4:
+		return uint64(binary.BigEndian.Uint32(b)), nil
+	}
+	return binary.BigEndian.Uint64(b), nil
+}
+
+// enter tracks the nesting depth of containers
+func (d *serialDecoder) enter() error {
+	if d.depth++; d.depth > maxDecodeDepth {
+		return fmt.Errorf("values nested too deeply")
+	}
+	return nil
+}
+
+// key writes a map key; keys that are not strings are written as their JSON text
+func (d *serialDecoder) key(decode func(d *serialDecoder) error) error {
+	start := len(d.out)
+	if err := decode(d); err != nil {
+		return err
+	}
+	if d.out[start] != '"' {
+		text := string(d.out[start:])
+		d.out = appendJSONString(d.out[:start], text)
+	}
+	d.out = append(d.out, ':')
+	return nil
+}
+
+// decodeMessagePack renders MessagePack data as JSON
+func decodeMessagePack(data []byte, options formatOptions) (string, bool) {
+	return decodeSequence(data, (*serialDecoder).msgpackValue, options)
+}
+
+// msgpackValue decodes one MessagePack value
+func (d *serialDecoder) msgpackValue() error {
+	b, err := d.next(1)
+	if err != nil {
+		return err
+	}
+	switch t := b[0]; {
+	case t <= 0x7f:
+		d.out = strconv.AppendUint(d.out, uint64(t), 10)
+	case t >= 0xe0:
+		d.out = strconv.AppendInt(d.out, int64(int8(t)), 10)
+	case t >= 0x80 && t <= 0x8f:
+		return d.msgpackMap(uint64(t & 0x0f))
+	case t >= 0x90 && t <= 0x9f:
+		return d.msgpackArray(uint64(t & 0x0f))
+	case t >= 0xa0 && t <= 0xbf:
+		return d.msgpackString(uint64(t & 0x1f))
+	case t == 0xc0:
+		d.out = append(d.out, "null"...)
+	case t == 0xc2:
+		d.out = append(d.out, "false"...)
+	case t == 0xc3:
+		d.out = append(d.out, "true"...)
+	case t >= 0xc4 && t <= 0xc6:
+		n, err := d.uint(1 << (t - 0xc4))
+		if err != nil {
+			return err
+		}
+		data, err := d.next(n)
+		if err != nil {
+			return err
+		}
+		d.out = appendJSONBytes(d.out, data)
+	case t >= 0xc7 && t <= 0xc9:
+		n, err := d.uint(1 << (t - 0xc7))
+		if err != nil {
+			return err
+		}
+		return d.msgpackExt(n)
+	case t == 0xca:
+		bits, err := d.uint(4)
+		if err != nil {
+			return err
+		}
+		d.out = appendJSONFloat(d.out, float64(math.Float32frombits(uint32(bits))), 32)
+	case t == 0xcb:
+		bits, err := d.uint(8)
+		if err != nil {
+			return err
+		}
+		d.out = appendJSONFloat(d.out, math.Float64frombits(bits), 64)
+	case t >= 0xcc && t <= 0xcf:
+		n, err := d.uint(1 << (t - 0xcc))
+		if err != nil {
+			return err
+		}
+		d.out = strconv.AppendUint(d.out, n, 10)
+	case t >= 0xd0 && t <= 0xd3:
+		size := 1 << (t - 0xd0)
+		n, err := d.uint(size)
+		if err != nil {
+			return err
+		}
+		// Sign extend from the encoded size
+		shift := 64 - 8*size
+		d.out = strconv.AppendInt(d.out, int64(n<<shift)>>shift, 10)
+	case t >= 0xd4 && t <= 0xd8:
+		return d.msgpackExt(1 << (t - 0xd4))
+	case t >= 0xd9 && t <= 0xdb:
+		n, err := d.uint(1 << (t - 0xd9))
+		if err != nil {
+			return err
+		}
+		return d.msgpackString(n)
+	case t == 0xdc || t == 0xdd:
+		n, err := d.uint(2 << (t - 0xdc))
+		if err != nil {
+			return err
+		}
+		return d.msgpackArray(n)
+	case t == 0xde || t == 0xdf:
+		n, err := d.uint(2 << (t - 0xde))
+		if err != nil {
+			return err
+		}
+		return d.msgpackMap(n)
+	default:
+		return fmt.Errorf("invalid MessagePack type 0x%02x", t)
+	}
+	return nil
+}
+
+func (d *serialDecoder) msgpackString(n uint64) error {
+	text, err := d.next(n)
+	if err != nil {
+		return err
+	}
+	d.out = appendJSONString(d.out, string(text))
+	return nil
+}
+
+func (d *serialDecoder) msgpackArray(n uint64) error {
+	if err := d.enter(); err != nil {
+		return err
+	}
+	d.out = append(d.out, '[')
+	for i := uint64(0); i < n; i++ {
+		if i > 0 {
+			d.out = append(d.out, ',')
+		}
+		if err := d.msgpackValue(); err != nil {
+			return err
+		}
+	}
+	d.out = append(d.out, ']')
+	d.depth--
+	return nil
+}
+
+func (d *serialDecoder) msgpackMap(n uint64) error {
+	if err := d.enter(); err != nil {
+		return err
+	}
+	d.out = append(d.out, '{')
+	for i := uint64(0); i < n; i++ {
+		if i > 0 {
+			d.out = append(d.out, ',')
+		}
+		if err := d.key((*serialDecoder).msgpackValue); err != nil {
+			return err
+		}
+		if err := d.msgpackValue(); err != nil {
+			return err
+		}
+	}
+	d.out = append(d.out, '}')
+	d.depth--
+	return nil
+}
+
+// msgpackExt decodes an extension value; the timestamp extension (-1) is shown as RFC 3339 time
+func (d *serialDecoder) msgpackExt(n uint64) error {
+	typeByte, err := d.next(1)
+	if err != nil {
+		return err
+	}
+	data, err := d.next(n)
+	if err != nil {
+		return err
+	}
+	extType := int8(typeByte[0])
+	if extType == -1 {
+		var t time.Time
+		switch len(data) {
+		case 4:
+			t = time.Unix(int64(binary.BigEndian.Uint32(data)), 0)
+		case 8:
+			value := binary.BigEndian.Uint64(data)
+			t = time.Unix(int64(value&(1<<34-1)), int64(value>>34))
+		case 12:
+			t = time.Unix(int64(binary.BigEndian.Uint64(data[4:])), int64(binary.BigEndian.Uint32(data)))
+		}
+		if !t.IsZero() {
+			d.out = appendJSONString(d.out, t.UTC().Format(time.RFC3339Nano))
+			return nil
+		}
+	}
+	d.out = fmt.Appendf(d.out, `{"ext":%d,"data":`, extType)
+	d.out = appendJSONBytes(d.out, data)
+	d.out = append(d.out, '}')
+	return nil
+}
+
+// decodeCBOR renders CBOR data (RFC 8949) as JSON
+func decodeCBOR(data []byte, options formatOptions) (string, bool) {
+	return decodeSequence(data, (*serialDecoder).cborValue, options)
+}
+
+// cborBreak is returned for the "break" stop code of indefinite length items
+var cborBreak = errors.New("unexpected CBOR break")
+
+// cborHead reads the initial byte and argument of an item; info 31 marks an indefinite length
+func (d *serialDecoder) cborHead() (major byte, info byte, argument uint64, err error) {
+	b, err := d.next(1)
+	if err != nil {
+		return 0, 0, 0, err
+	}
+	major, info = b[0]>>5, b[0]&0x1f
+	switch {
+	case info < 24:
+		argument = uint64(info)
+	case info <= 27:
+		argument, err = d.uint(1 << (info - 24))
+	case info == 31:
+		if major == 7 {
+			return major, info, 0, cborBreak
+		}
+	default:
+		err = fmt.Errorf("invalid CBOR item 0x%02x", b[0])
+	}
+	return major, info, argument, err
+}
+
+// cborValue decodes one CBOR data item
+func (d *serialDecoder) cborValue() error {
+	major, info, argument, err := d.cborHead()
+	if err != nil {
+		return err
+	}
+	indefinite := info == 31
+	switch major {
+	case 0:
+		d.out = strconv.AppendUint(d.out, argument, 10)
+	case 1:
+		// -1 - argument, which may exceed int64
+		value := new(big.Int).SetUint64(argument)
+		d.out = value.Neg(value).Sub(value, big.NewInt(1)).Append(d.out, 10)
+	case 2, 3:
+		data, err := d.cborString(major, argument, indefinite)
+		if err != nil {
+			return err
+		}
+		if major == 2 {
+			d.out = appendJSONBytes(d.out, data)
+		} else {
+			d.out = appendJSONString(d.out, string(data))
+		}
+	case 4, 5:
+		if err := d.enter(); err != nil {
+			return err
+		}
+		opening, closing := byte('['), byte(']')
+		if major == 5 {
+			opening, closing = '{', '}'
+		}
+		d.out = append(d.out, opening)
+		for i := uint64(0); indefinite || i < argument; i++ {
+			// Only a break in place of the next item ends the container, nested ones are errors
+			if indefinite && d.pos < len(d.data) && d.data[d.pos] == 0xff {
+				d.pos++
+				break
+			}
+			if i > 0 {
+				d.out = append(d.out, ',')
+			}
+			if major == 5 {
+				err = d.key((*serialDecoder).cborValue)
+			} else {
+				err = d.cborValue()
+			}
+			if err != nil {
+				return err
+			}
+			if major == 5 {
+				if err := d.cborValue(); err != nil {
+					return err
+				}
+			}
+		}
+		d.out = append(d.out, closing)
+		d.depth--
+	case 6:
+		return d.cborTag(argument)
+	case 7:
+		switch {
+		case info == 20:
+			d.out = append(d.out, "false"...)
+		case info == 21:
+			d.out = append(d.out, "true"...)
+		case info == 22, info == 23:
+			d.out = append(d.out, "null"...) // null and undefined
+		case info == 25:
+			d.out = appendJSONFloat(d.out, halfFloat(uint16(argument)), 32)
+		case info == 26:
+			d.out = appendJSONFloat(d.out, float64(math.Float32frombits(uint32(argument))), 32)
+		case info == 27:
+			d.out = appendJSONFloat(d.out, math.Float64frombits(argument), 64)
+		default:
+			d.out = fmt.Appendf(d.out, `{"simple":%d}`, argument)
+		}
+	}
+	return nil
+}
+
+// cborString reads a byte or text string, joining the chunks of an indefinite length string
+func (d *serialDecoder) cborString(major byte, length uint64, indefinite bool) ([]byte, error) {
+	if !indefinite {
+		return d.next(length)
+	}
+	var joined []byte
+	for {
+		chunkMajor, _, chunkLength, err := d.cborHead()
+		if err == cborBreak {
+			return joined, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		if chunkMajor != major {
+			return nil, fmt.Errorf("invalid chunk in indefinite length CBOR string")
+		}
+		chunk, err := d.next(chunkLength)
+		if err != nil {
+			return nil, err
+		}
+		joined = append(joined, chunk...)
+	}
+}
+
+// cborTag decodes a tagged item. Date/time and bignum tags are shown as their values,
+// other tags as {"tag": number, "value": item}.
+func (d *serialDecoder) cborTag(tag uint64) error {
+	// Chains of tags nest like containers
+	if err := d.enter(); err != nil {
+		return err
+	}
+	defer func() { d.depth-- }()
+	switch tag {
+	case 0, 55799:
+		// Date/time string and the self-described CBOR marker
+		return d.cborValue()
+	case 1:
+		start := len(d.out)
+		if err := d.cborValue(); err != nil {
+			return err
+		}
+		if seconds, err := strconv.ParseFloat(string(d.out[start:]), 64); err == nil {
+			t := time.Unix(0, int64(seconds*float64(time.Second))).UTC()
+			d.out = appendJSONString(d.out[:start], t.Format(time.RFC3339Nano))
+		}
+		return nil
+	case 2, 3:
+		major, info, argument, err := d.cborHead()
+		if err != nil {
+			return err
+		}
+		if major != 2 {
+			return fmt.Errorf("invalid CBOR bignum")
+		}
+		data, err := d.cborString(major, argument, info == 31)
+		if err != nil {
+			return err
+		}
+		value := new(big.Int).SetBytes(data)
+		if tag == 3 {
+			value.Neg(value).Sub(value, big.NewInt(1))
+		}
+		d.out = value.Append(d.out, 10)
+		return nil
+	}
+	d.out = fmt.Appendf(d.out, `{"tag":%d,"value":`, tag)
+	if err := d.cborValue(); err != nil {
+		return err
+	}
+	d.out = append(d.out, '}')
+	return nil
+}
+
+// halfFloat converts an IEEE 754 half precision number
+func halfFloat(bits uint16) float64 {
+	exponent := int(bits>>10) & 0x1f
+	mantissa := float64(bits & 0x3ff)
+	var value float64
+	switch exponent {
+	case 0:
+		value = math.Ldexp(mantissa, -24)
+	case 31:
+		if mantissa == 0 {
+			value = math.Inf(1)
+		} else {
+			value = math.NaN()
+		}
+	default:
+		value = math.Ldexp(mantissa+1024, exponent-25)
+	}
+	if bits&0x8000 != 0 {
+		value = -value
+	}
+	return value
+}
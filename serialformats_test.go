@@ -0,0 +1,53 @@
+package main
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestDecodeCBOR(t *testing.T) {
+	tests := []struct {
+		name string
+		data []byte
+		want string // Empty if the data is invalid
+	}{
+		{"indefinite array", []byte{0x9f, 0x01, 0x02, 0xff}, "[1,2]"},
+		{"nested indefinite arrays", []byte{0x9f, 0x01, 0x9f, 0xff, 0xff}, "[1,[]]"},
+		{"indefinite map", []byte{0xbf, 0x61, 'a', 0x01, 0xff}, `{"a":1}`},
+		{"break in a definite array", []byte{0x9f, 0x81, 0xff}, ""},
+		{"break as map value", []byte{0xbf, 0x61, 'a', 0xff}, ""},
+		{"break at the top", []byte{0xff}, ""},
+		{"chain of tags", append(bytes.Repeat([]byte{0xc6}, 1<<20), 0x01), ""},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got, ok := decodeCBOR(test.data, formatOptions{})
+			if ok != (test.want != "") || got != test.want {
+				t.Errorf("decodeCBOR(% x) = %q, %v, want %q", test.data[:min(len(test.data), 8)], got, ok, test.want)
+			}
+		})
+	}
+}
+
+func TestDecodeProtobufGroups(t *testing.T) {
+	// Field 1 as group holding field 2 = 1
+	if got, ok := decodeProtobuf([]byte{0x0b, 0x10, 0x01, 0x0c}, nil, nil, formatOptions{}); !ok || got != `{"1":{"2":1}}` {
+		t.Errorf("decoding a group = %q, %v", got, ok)
+	}
+	if _, ok := decodeProtobuf(bytes.Repeat([]byte{0x0b}, 1<<20), nil, nil, formatOptions{}); ok {
+		t.Error("deeply nested groups were decoded")
+	}
+}
+
+func TestParseMapFieldNames(t *testing.T) {
+	schema := &protoSchema{messages: map[string]*protoMessageType{}, enums: map[string]map[int64]string{}}
+	if err := schema.parseProtoFile(`syntax = "proto3"; message M { map<string, string> _ = 1; }`); err == nil {
+		t.Error("a map field without name was accepted")
+	}
+	if err := schema.parseProtoFile(`syntax = "proto3"; message N { map<string, int32> label_count = 1; }`); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := schema.messages["N.LabelCountEntry"]; !ok {
+		t.Errorf("entry types %v, want N.LabelCountEntry", schema.messages)
+	}
+}
@@ -7,8 +7,18 @@ import (
 )
 
 type Settings struct {
-	RecentProjects []string          `json:"recentProjects"`
-	Certificate    CertificateConfig `json:"certificate"`
+	RecentProjects []string                             `json:"recentProjects"`
+	Certificate    CertificateConfig                    `json:"certificate"`
+	Proxy          ProxyConfig                          `json:"proxy"`
+	Cookies        map[string]map[string][]StoredCookie `json:"cookies,omitempty"` // Persisted cookies by project file and environment base URL
+	// Response bodies larger than this are spilled to a temporary file, 0 uses the default of 10 MB
+	MaxResponseMemoryMB int `json:"maxResponseMemoryMB,omitempty"`
+	// Indent of formatted JSON: a number of spaces or "tab", empty uses two spaces
+	JSONIndent string `json:"jsonIndent,omitempty"`
+	// Sends approve their response as snapshot instead of comparing it with the approved one
+	UpdateSnapshots bool `json:"updateSnapshots,omitempty"`
+
+	clients clientCache // Not saved, HTTP clients reused between sends
 }
 
 func settingsFilePath() string {
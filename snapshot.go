@@ -0,0 +1,404 @@
+package main
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// SnapshotConfig makes sends of a request assert that the response matches its approved snapshot
+type SnapshotConfig struct {
+	Headers []string `json:"headers,omitempty"` // Compared headers, Content-Type if empty
+	// Volatile parts: JSON paths like "$.meta.requestId" or ".items[].createdAt",
+	// regular expressions like "/req-[0-9]+/" and the masks "uuid" and "timestamp"
+	Ignore []string `json:"ignore,omitempty"`
+}
+
+// Snapshot is an approved response, stored as golden file next to the project file
+type Snapshot struct {
+	Approved    time.Time `json:"approved"`
+	StatusCode  int       `json:"statusCode"`
+	Status      string    `json:"status"`
+	Headers     Params    `json:"headers,omitempty"` // The compared headers
+	ContentType string    `json:"contentType,omitempty"`
+	Body        string    `json:"body"`             // Decoded body text, base64 for binary bodies
+	Binary      bool      `json:"binary,omitempty"` // The body is base64 encoded
+}
+
+// snapshotMasks are the named masks of ignore rules with their replacement
+var snapshotMasks = map[string]*regexp.Regexp{
+	"uuid": regexp.MustCompile(`[0-9a-fA-F]{8}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{12}`),
+	// ISO 8601 date-times and HTTP dates
+	"timestamp": regexp.MustCompile(`\d{4}-\d{2}-\d{2}[T ]\d{2}:\d{2}(:\d{2}(\.\d+)?)?(Z|[+-]\d{2}:?\d{2})?|(Mon|Tue|Wed|Thu|Fri|Sat|Sun), \d{2} [A-Z][a-z]{2} \d{4} \d{2}:\d{2}:\d{2} GMT`),
+}
+
+// ignoredValue replaces values matched by a JSON path of an ignore rule
+const ignoredValue = "<ignored>"
+
+// snapshotMask is a regular expression replaced before comparing
+type snapshotMask struct {
+	pattern     *regexp.Regexp
+	replacement string
+}
+
+// snapshotRules are the compiled ignore rules of a snapshot
+type snapshotRules struct {
+	paths [][]jsonPathStep
+	masks []snapshotMask
+}
+
+// compileSnapshotRules parses the ignore rules
+func compileSnapshotRules(ignore []string) (snapshotRules, error) {
+	var rules snapshotRules
+	for _, rule := range ignore {
+		rule = strings.TrimSpace(rule)
+		switch {
+		case rule == "":
+		case snapshotMasks[strings.ToLower(rule)] != nil:
+			name := strings.ToLower(rule)
+			rules.masks = append(rules.masks, snapshotMask{snapshotMasks[name], "<" + name + ">"})
+		case len(rule) > 1 && strings.HasPrefix(rule, "/") && strings.HasSuffix(rule, "/"):
+			pattern, err := regexp.Compile(rule[1 : len(rule)-1])
+			if err != nil {
+				return rules, fmt.Errorf("invalid ignore pattern %s: %v", rule, err)
+			}
+			rules.masks = append(rules.masks, snapshotMask{pattern, "<masked>"})
+		default:
+			steps, err := parseJSONPath(rule)
+			if err != nil {
+				return rules, fmt.Errorf("invalid ignore path %s: %v", rule, err)
+			}
+			rules.paths = append(rules.paths, steps)
+		}
+	}
+	return rules, nil
+}
+
+// mask replaces the masked parts of a text
+func (rules snapshotRules) mask(text string) string {
+	for _, mask := range rules.masks {
+		text = mask.pattern.ReplaceAllString(text, mask.replacement)
+	}
+	return text
+}
+
+// maskJSON applies the masks to the strings of a JSON value
+func (rules snapshotRules) maskJSON(value any) any {
+	switch value := value.(type) {
+	case string:
+		return rules.mask(value)
+	case []any:
+		for i := range value {
+			value[i] = rules.maskJSON(value[i])
+		}
+	case *jsonObject:
+		for _, key := range value.keys {
+			value.values[key] = rules.maskJSON(value.values[key])
+		}
+	}
+	return value
+}
+
+// jsonPathStep is a step of a JSON path: a field, an index, all elements ("*")
+// or a field at any depth ("..")
+type jsonPathStep struct {
+	kind  byte // 'f' field, 'i' index, '*' all elements, 'r' field at any depth
+	name  string
+	index int
+}
+
+// parseJSONPath parses paths of fields and indexes such as "$.items[*].id", ".items[].id",
+// "$..createdAt" or `$['odd key'][0]`
+func parseJSONPath(path string) ([]jsonPathStep, error) {
+	tokens, err := tokenizeJQ(path)
+	if err != nil {
+		return nil, err
+	}
+	if len(tokens) > 0 && (tokens[0] == "$" || tokens[0] == ".") {
+		tokens = tokens[1:]
+	}
+	var steps []jsonPathStep
+	for i := 0; i < len(tokens); i++ {
+		token := tokens[i]
+		switch {
+		case strings.HasPrefix(token, "..") && len(token) > 2:
+			steps = append(steps, jsonPathStep{kind: 'r', name: token[2:]})
+		case strings.HasPrefix(token, ".") && len(token) > 1 && token != "..":
+			steps = append(steps, jsonPathStep{kind: 'f', name: token[1:]})
+		case token == "." && i+1 < len(tokens) && tokens[i+1] == "[":
+			// ".[0]" is the same as "[0]"
+		case token == "[" && i+1 < len(tokens):
+			i++
+			inner := tokens[i]
+			switch {
+			case inner == "]":
+				steps = append(steps, jsonPathStep{kind: '*'})
+				continue
+			case inner == "*":
+				steps = append(steps, jsonPathStep{kind: '*'})
+			case isJQString(inner):
+				name, err := jqString(inner)
+				if err != nil {
+					return nil, err
+				}
+				steps = append(steps, jsonPathStep{kind: 'f', name: name})
+			default:
+				// Negative indexes count from the end
+				if inner == "-" && i+1 < len(tokens) {
+					i++
+					inner += tokens[i]
+				}
+				index, err := strconv.Atoi(inner)
+				if err != nil {
+					return nil, fmt.Errorf("unexpected %q in brackets", inner)
+				}
+				steps = append(steps, jsonPathStep{kind: 'i', index: index})
+			}
+			if i+1 >= len(tokens) || tokens[i+1] != "]" {
+				return nil, errors.New(`expected "]"`)
+			}
+			i++
+		default:
+			return nil, fmt.Errorf("unexpected %q", token)
+		}
+	}
+	return steps, nil
+}
+
+// ignoreJSONPath replaces the values matched by the path steps
+func ignoreJSONPath(value any, steps []jsonPathStep) any {
+	if len(steps) == 0 {
+		return ignoredValue
+	}
+	step, rest := steps[0], steps[1:]
+	switch value := value.(type) {
+	case *jsonObject:
+		for _, key := range value.keys {
+			switch {
+			case step.kind == '*' || (step.kind == 'f' || step.kind == 'r') && key == step.name:
+				value.values[key] = ignoreJSONPath(value.values[key], rest)
+			case step.kind == 'r':
+				value.values[key] = ignoreJSONPath(value.values[key], steps)
+			}
+		}
+	case []any:
+		for i := range value {
+			switch {
+			case step.kind == '*' || step.kind == 'i' && (i == step.index || i == step.index+len(value)):
+				value[i] = ignoreJSONPath(value[i], rest)
+			case step.kind == 'r':
+				value[i] = ignoreJSONPath(value[i], steps)
+			}
+		}
+	}
+	return value
+}
+
+// snapshotsDir returns the directory of the approved snapshots, empty if the project was not saved yet
+func (p *Project) snapshotsDir() string {
+	if p.filePath == "" {
+		return ""
+	}
+	return p.filePath + ".snapshots"
+}
+
+// snapshotFile returns the golden file of a request
+func (p *Project) snapshotFile(requestID string) string {
+	return filepath.Join(p.snapshotsDir(), requestID+".json")
+}
+
+// loadSnapshot returns the approved snapshot of a request, nil if none was approved yet
+func (p *Project) loadSnapshot(request *Request) (*Snapshot, error) {
+	if p.snapshotsDir() == "" || request.ID == "" {
+		return nil, nil
+	}
+	data, err := os.ReadFile(p.snapshotFile(request.ID))
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error reading snapshot: %v", err)
+	}
+	var snapshot Snapshot
+	if err := json.Unmarshal(data, &snapshot); err != nil {
+		return nil, fmt.Errorf("error reading snapshot: %v", err)
+	}
+	return &snapshot, nil
+}
+
+// approveSnapshot stores the response as approved snapshot of the request
+func (p *Project) approveSnapshot(request *Request, response *ResponseData) error {
+	if p.snapshotsDir() == "" {
+		return errors.New("snapshots are stored once the project is saved")
+	}
+	if request.ID == "" {
+		request.ID = newRequestID()
+	}
+	snapshot := Snapshot{
+		Approved:    time.Now(),
+		StatusCode:  response.StatusCode,
+		Status:      response.Status,
+		Headers:     request.Snapshot.selectHeaders(response.Headers),
+		ContentType: response.ContentType,
+	}
+	if source, text := response.diffSource(); text {
+		snapshot.Body = string(source)
+	} else {
+		snapshot.Body = base64.StdEncoding.EncodeToString(source)
+		snapshot.Binary = true
+	}
+	data, err := json.MarshalIndent(snapshot, "", "  ")
+	if err != nil {
+		return fmt.Errorf("error storing snapshot: %v", err)
+	}
+	if err := os.MkdirAll(p.snapshotsDir(), 0755); err != nil {
+		return fmt.Errorf("error creating snapshot directory: %v", err)
+	}
+	if err := os.WriteFile(p.snapshotFile(request.ID), data, 0644); err != nil {
+		return fmt.Errorf("error storing snapshot: %v", err)
+	}
+	return nil
+}
+
+// selectHeaders returns the compared headers
+func (config *SnapshotConfig) selectHeaders(headers Params) Params {
+	names := []string{"Content-Type"}
+	if config != nil && len(config.Headers) > 0 {
+		names = config.Headers
+	}
+	var selected Params
+	for _, name := range names {
+		for _, value := range headers.Values(name) {
+			selected = append(selected, Param{Name: name, Value: value})
+		}
+	}
+	return selected
+}
+
+// response returns the snapshot as response, shown like responses of the request
+func (snapshot *Snapshot) response(request *Request, settings *Settings, baseDir string) *ResponseData {
+	response := &ResponseData{
+		StatusCode:   snapshot.StatusCode,
+		Status:       snapshot.Status,
+		Headers:      snapshot.Headers,
+		Timestamp:    snapshot.Approved,
+		ContentType:  snapshot.ContentType,
+		Decompressed: true,
+		format:       request.responseFormat(snapshot.ContentType, settings, baseDir),
+	}
+	if snapshot.Binary {
+		response.Raw, _ = base64.StdEncoding.DecodeString(snapshot.Body)
+	} else {
+		// The body was stored decoded, binary serialization formats as JSON
+		response.Raw = []byte(snapshot.Body)
+		response.format.charset = "utf-8"
+		if formatter := chosenFormatter(snapshot.ContentType, response.format); formatter != nil && formatter.Decode != nil {
+			response.format.formatter = "json"
+		}
+	}
+	response.Size = int64(len(response.Raw))
+	return response
+}
+
+// normalize returns the compared parts of a response with the ignore rules applied:
+// the status, the selected headers and the body. JSON bodies stay JSON.
+func (config *SnapshotConfig) normalize(response *ResponseData, rules snapshotRules) *ResponseData {
+	normalized := &ResponseData{
+		StatusCode: response.StatusCode,
+		Status:     response.Status,
+		Timestamp:  response.Timestamp,
+	}
+	for _, header := range config.selectHeaders(response.Headers) {
+		normalized.Headers = append(normalized.Headers, Param{Name: header.Name, Value: rules.mask(header.Value)})
+	}
+	source, text := response.diffSource()
+	if text {
+		if values, err := parseJSONValues(source); err == nil {
+			var records []string
+			for _, value := range values {
+				value = rules.maskJSON(value)
+				for _, path := range rules.paths {
+					value = ignoreJSONPath(value, path)
+				}
+				records = append(records, string(appendJSONValue(nil, value)))
+			}
+			normalized.Text = strings.Join(records, "\r\n")
+			return normalized
+		}
+	}
+	normalized.Text = rules.mask(response.Body())
+	return normalized
+}
+
+// compareSnapshot compares a response with the approved snapshot, ignoring the volatile parts
+func (p *Project) compareSnapshot(request *Request, snapshot *Snapshot, response *ResponseData, settings *Settings) (ResponseDiff, error) {
+	rules, err := compileSnapshotRules(request.Snapshot.ignoreRules())
+	if err != nil {
+		return ResponseDiff{}, err
+	}
+	approved := snapshot.response(request, settings, p.dir())
+	return diffResponses(
+		request.Snapshot.normalize(approved, rules),
+		request.Snapshot.normalize(response, rules),
+		"approved snapshot ("+snapshot.Approved.Format("2006-01-02 15:04:05")+")",
+		"response ("+response.Timestamp.Format("2006-01-02 15:04:05")+")",
+	), nil
+}
+
+// ignoreRules returns the ignore rules, none without configuration
+func (config *SnapshotConfig) ignoreRules() []string {
+	if config == nil {
+		return nil
+	}
+	return config.Ignore
+}
+
+// assertSnapshot compares a response of the request with its approved snapshot. The first
+// response is approved, as is every response in update mode. A response that differs gets
+// a failure. It returns a message describing the outcome.
+func (p *Project) assertSnapshot(request *Request, response *ResponseData, settings *Settings) (string, error) {
+	snapshot, err := p.loadSnapshot(request)
+	if err != nil {
+		return "", err
+	}
+	if snapshot == nil || (settings != nil && settings.UpdateSnapshots) {
+		if err := p.approveSnapshot(request, response); err != nil {
+			return "", err
+		}
+		if snapshot == nil {
+			return "📸 Snapshot recorded", nil
+		}
+		return "📸 Snapshot updated", nil
+	}
+	diff, err := p.compareSnapshot(request, snapshot, response, settings)
+	if err != nil {
+		return "", err
+	}
+	if diff.Equal() {
+		return "✅ Snapshot matches", nil
+	}
+	changes := len(diff.Status) + len(diff.Headers) + len(diff.Body)
+	if len(diff.Lines) > 0 || diff.Note != "" {
+		changes++
+	}
+	response.Failures = append(response.Failures, fmt.Sprintf("snapshot differs in %d place(s), see Diff...", changes))
+	return "⚠ Snapshot differs", nil
+}
+
+// splitSnapshotRules splits the headers or ignore rules entered as list
+func splitSnapshotRules(text string, separator string) []string {
+	var rules []string
+	for _, rule := range strings.Split(text, separator) {
+		if rule = strings.TrimSpace(rule); rule != "" {
+			rules = append(rules, rule)
+		}
+	}
+	return rules
+}
@@ -0,0 +1,187 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"mime"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// StreamEvent is a Server-Sent Event, or a line or chunk of another streamed response
+type StreamEvent struct {
+	Time  time.Time // When the event was received
+	Event string    // SSE event type, empty for the default "message"
+	ID    string    // SSE last event ID
+	Data  string    // Event data; lines of multi-line data are joined with '\n'
+	Retry int       // SSE reconnection time in milliseconds, 0 if not sent
+}
+
+// String formats the event as transcript entry
+func (event StreamEvent) String() string {
+	var builder strings.Builder
+	builder.WriteString(event.Time.Format("15:04:05.000"))
+	if event.Event != "" {
+		builder.WriteString(" [" + event.Event + "]")
+	}
+	if event.ID != "" {
+		builder.WriteString(" id=" + event.ID)
+	}
+	if event.Retry > 0 {
+		fmt.Fprintf(&builder, " retry=%dms", event.Retry)
+	}
+	builder.WriteString(" ")
+	builder.WriteString(strings.ReplaceAll(event.Data, "\n", "\r\n    "))
+	return builder.String()
+}
+
+// streamKind selects how a streamed response body is split into events
+type streamKind int
+
+const (
+	streamNone   streamKind = iota // Read the body completely
+	streamSSE                      // text/event-stream
+	streamLines                    // Newline delimited JSON and similar, one event per line
+	streamChunks                   // Any other content type, one event per received chunk
+)
+
+// streamKindOf detects streaming responses by their content type.
+// If forced is set, other responses are streamed chunk by chunk.
+func streamKindOf(contentType string, forced bool) streamKind {
+	mediaType, _, _ := mime.ParseMediaType(contentType)
+	switch strings.ToLower(mediaType) {
+	case "text/event-stream":
+		return streamSSE
+	case "application/x-ndjson", "application/ndjson", "application/jsonl", "application/x-jsonlines", "application/stream+json", "application/json-seq":
+		return streamLines
+	}
+	if forced {
+		return streamChunks
+	}
+	return streamNone
+}
+
+// readStream reads the body until it ends and calls onEvent for every event
+func readStream(body io.Reader, kind streamKind, onEvent func(StreamEvent)) error {
+	if kind == streamChunks {
+		buffer := make([]byte, 32*1024)
+		for {
+			n, err := body.Read(buffer)
+			if n > 0 {
+				onEvent(StreamEvent{Time: time.Now(), Data: string(buffer[:n])})
+			}
+			if err == io.EOF {
+				return nil
+			}
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	scanner := bufio.NewScanner(body)
+	scanner.Buffer(make([]byte, 64*1024), 16<<20)
+	scanner.Split(scanStreamLines)
+	if kind == streamLines {
+		for scanner.Scan() {
+			if line := strings.TrimSpace(scanner.Text()); line != "" {
+				// RFC 7464 JSON text sequences prefix each record with RS
+				onEvent(StreamEvent{Time: time.Now(), Data: strings.TrimPrefix(line, "\x1e")})
+			}
+		}
+		return scanner.Err()
+	}
+
+	parser := sseParser{}
+	for scanner.Scan() {
+		if event, ok := parser.line(scanner.Text()); ok {
+			onEvent(event)
+		}
+	}
+	return scanner.Err()
+}
+
+// scanStreamLines splits at "\r\n", "\n" or "\r", as required for event streams
+func scanStreamLines(data []byte, atEOF bool) (int, []byte, error) {
+	if atEOF && len(data) == 0 {
+		return 0, nil, nil
+	}
+	if i := bytes.IndexAny(data, "\r\n"); i >= 0 {
+		if data[i] == '\n' {
+			return i + 1, data[:i], nil
+		}
+		// A '\r' at the end of the buffer may be followed by '\n'
+		if i+1 == len(data) && !atEOF {
+			return 0, nil, nil
+		}
+		if i+1 < len(data) && data[i+1] == '\n' {
+			return i + 2, data[:i], nil
+		}
+		return i + 1, data[:i], nil
+	}
+	if atEOF {
+		return len(data), data, nil
+	}
+	return 0, nil, nil
+}
+
+// sseParser interprets the lines of an event stream
+// (HTML Living Standard, "Interpreting an event stream")
+type sseParser struct {
+	event   string
+	data    strings.Builder
+	hasData bool
+	lastID  string
+	retry   int
+}
+
+// line processes a line and returns the event completed by an empty line
+func (p *sseParser) line(line string) (StreamEvent, bool) {
+	if line == "" {
+		if !p.hasData {
+			p.event = ""
+			return StreamEvent{}, false
+		}
+		event := StreamEvent{Time: time.Now(), Event: p.event, ID: p.lastID, Data: p.data.String(), Retry: p.retry}
+		p.event, p.hasData, p.retry = "", false, 0
+		p.data.Reset()
+		return event, true
+	}
+	if strings.HasPrefix(line, ":") {
+		return StreamEvent{}, false // Comment, e.g. keep-alive
+	}
+	field, value, _ := strings.Cut(line, ":")
+	value = strings.TrimPrefix(value, " ")
+	switch field {
+	case "event":
+		p.event = value
+	case "data":
+		if p.hasData {
+			p.data.WriteByte('\n')
+		}
+		p.data.WriteString(value)
+		p.hasData = true
+	case "id":
+		if !strings.Contains(value, "\x00") {
+			p.lastID = value
+		}
+	case "retry":
+		if retry, err := strconv.Atoi(value); err == nil && retry >= 0 {
+			p.retry = retry
+		}
+	}
+	return StreamEvent{}, false
+}
+
+// formatStreamEvents renders the received events as response body
+func formatStreamEvents(events []StreamEvent) string {
+	var builder strings.Builder
+	for _, event := range events {
+		builder.WriteString(event.String())
+		builder.WriteString("\r\n")
+	}
+	return builder.String()
+}
@@ -1,17 +1,44 @@
 package main
 
 import (
+	"context"
 	"time"
 )
 
 // ResponseData holds information about a single HTTP response
 type ResponseData struct {
-	Body       string            // Response body
-	Headers    map[string]string // Response headers
-	StatusCode int               // HTTP status code
-	Status     string            // Status text (e.g., "200 OK")
-	Duration   time.Duration     // Time taken for the request
-	Timestamp  time.Time         // When the response was received
+	Headers    Params        // Response headers, repeated names keep every value
+	StatusCode int           // HTTP status code
+	Status     string        // Status text (e.g., "200 OK")
+	Duration   time.Duration // Time taken for the request
+	Timestamp  time.Time     // When the response was received
+	RoundTrips []RoundTrip   // Individual round trips, more than one if authentication was retried
+	Timing     Timing        // Phases of the final round trip including the body transfer
+	Failures   []string      // Problems reported by a successful response, e.g. GraphQL errors
+	Events     []StreamEvent // Events of a streamed response, e.g. Server-Sent Events
+	Cancelled  bool          // The send was cancelled, e.g. a stream stopped by the user
+	Filter     string        // Filter expression narrowing the displayed body, e.g. ".items[] | .id"
+
+	// Body exactly as received; Body() formats it for display on first use
+	Raw           []byte // Body bytes as received, only the first part if Truncated
+	Size          int64  // Size of the complete body in bytes
+	Truncated     bool   // The body exceeded the memory cap, the complete body is in BodyFile
+	BodyFile      string // Temporary file holding the complete body of a truncated response
+	ContentType   string // Content-Type of the body
+	Encoding      string // Content-Encoding the server applied, e.g. "gzip"
+	Decompressed  bool   // The transport removed the Content-Encoding, Raw holds the decompressed body
+	ContentLength int64  // Content-Length announced by the server, -1 if not sent
+	Text          string // Display text of responses without a body, e.g. errors and WebSocket transcripts
+
+	view   *responseView // Formatted body, cached on first use; copies made before that format again
+	format formatOptions // How the body is displayed
+}
+
+// RoundTrip records a single HTTP exchange that was part of a send
+type RoundTrip struct {
+	Status   string        // Status text of this exchange
+	Duration time.Duration // Time taken for this exchange until the response headers arrived
+	Timing   Timing        // Phases of this exchange
 }
 
 // RequestTabContent holds state specific to request editing tabs
@@ -20,8 +47,12 @@ type RequestTabContent struct {
 	BoundProject *Project // Reference to the project for settings
 	Path         string   // URL path for the request
 	Settings     *Settings
-	Responses    []ResponseData // Multiple responses (newest first)
-	Pending      bool           // Whether the request is pending execution
+	Responses    []ResponseData     // Multiple responses (newest first)
+	Pending      bool               // Whether the request is pending execution
+	FreshConn    bool               // Whether sends open a new connection (cold timings)
+	WebSocket    *WebSocketSession  // Open connection of a WebSocket request
+	cancel       context.CancelFunc // Cancels all running sends, nil if none
+	running      int                // Number of running sends
 }
 
 // TreeNodeInfo stores metadata about a tree item
@@ -36,13 +67,22 @@ type TreeNodeInfo struct {
 // ProjectViewTabContent holds state specific to project view tabs
 type ProjectViewTabContent struct {
 	BoundProject   *Project // Direct binding to the Project object
-	SelectedIndex  int      // Currently selected request index in listbox
-	ScrollPosition int      // Scroll position in the listbox
+	Settings       *Settings
+	SelectedIndex  int // Currently selected request index in listbox
+	ScrollPosition int // Scroll position in the listbox
 	itemToNodeInfo map[uintptr]*TreeNodeInfo
 	ExpandedPaths  []string // Paths expanded in tree view
 	SelectedPath   string   // Last selected path in tree view
 }
 
+// ReportTabContent holds state specific to report tabs, e.g. of an environment comparison
+type ReportTabContent struct {
+	Status   string             // Progress while the report is produced, then its outcome
+	Text     string             // Text of the report, empty until it is finished
+	FileName string             // Suggested name of a saved report
+	cancel   context.CancelFunc // Stops producing the report, nil once it is finished
+}
+
 // SettingsTabContent holds state specific to settings tabs
 type SettingsTabContent struct {
 	Settings *Settings
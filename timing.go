@@ -0,0 +1,118 @@
+package main
+
+import (
+	"crypto/tls"
+	"fmt"
+	"net/http"
+	"net/http/httptrace"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Timing breaks the duration of a round trip down into its phases
+type Timing struct {
+	DNS        time.Duration // Name resolution, zero if not needed
+	Connect    time.Duration // TCP connect, zero if the connection was reused
+	TLS        time.Duration // TLS handshake, zero for plain HTTP or reused connections
+	FirstByte  time.Duration // From start of the round trip to the first response byte
+	Transfer   time.Duration // Reading the response body after the first byte
+	Reused     bool          // Whether a kept-alive connection was reused
+	RemoteAddr string        // Remote address of the connection (IP:port)
+}
+
+// String formats the timing for the response info line
+func (t Timing) String() string {
+	var parts []string
+	if t.DNS > 0 {
+		parts = append(parts, fmt.Sprintf("DNS %v", t.DNS.Round(time.Microsecond)))
+	}
+	if t.Connect > 0 {
+		parts = append(parts, fmt.Sprintf("Connect %v", t.Connect.Round(time.Microsecond)))
+	}
+	if t.TLS > 0 {
+		parts = append(parts, fmt.Sprintf("TLS %v", t.TLS.Round(time.Microsecond)))
+	}
+	parts = append(parts, fmt.Sprintf("TTFB %v", t.FirstByte.Round(time.Microsecond)))
+	parts = append(parts, fmt.Sprintf("Transfer %v", t.Transfer.Round(time.Microsecond)))
+	if t.Reused {
+		parts = append(parts, "reused connection")
+	} else {
+		parts = append(parts, "new connection")
+	}
+	if t.RemoteAddr != "" {
+		parts = append(parts, t.RemoteAddr)
+	}
+	return strings.Join(parts, " | ")
+}
+
+// timingTrace collects the phases of a single round trip
+type timingTrace struct {
+	mu           sync.Mutex
+	start        time.Time
+	dnsStart     time.Time
+	connectStart time.Time
+	tlsStart     time.Time
+	timing       Timing
+}
+
+// traceRequest attaches an httptrace to the request that measures its phases
+func traceRequest(req *http.Request) (*http.Request, *timingTrace) {
+	t := &timingTrace{start: time.Now()}
+	trace := &httptrace.ClientTrace{
+		DNSStart: func(httptrace.DNSStartInfo) {
+			t.mu.Lock()
+			defer t.mu.Unlock()
+			t.dnsStart = time.Now()
+		},
+		DNSDone: func(httptrace.DNSDoneInfo) {
+			t.mu.Lock()
+			defer t.mu.Unlock()
+			t.timing.DNS = time.Since(t.dnsStart)
+		},
+		ConnectStart: func(string, string) {
+			t.mu.Lock()
+			defer t.mu.Unlock()
+			// Several attempts may race (happy eyeballs); measure from the first one
+			if t.connectStart.IsZero() {
+				t.connectStart = time.Now()
+			}
+		},
+		ConnectDone: func(string, string, error) {
+			t.mu.Lock()
+			defer t.mu.Unlock()
+			t.timing.Connect = time.Since(t.connectStart)
+		},
+		TLSHandshakeStart: func() {
+			t.mu.Lock()
+			defer t.mu.Unlock()
+			t.tlsStart = time.Now()
+		},
+		TLSHandshakeDone: func(tls.ConnectionState, error) {
+			t.mu.Lock()
+			defer t.mu.Unlock()
+			t.timing.TLS = time.Since(t.tlsStart)
+		},
+		GotConn: func(info httptrace.GotConnInfo) {
+			t.mu.Lock()
+			defer t.mu.Unlock()
+			t.timing.Reused = info.Reused
+			if info.Conn != nil {
+				t.timing.RemoteAddr = info.Conn.RemoteAddr().String()
+			}
+		},
+		GotFirstResponseByte: func() {
+			t.mu.Lock()
+			defer t.mu.Unlock()
+			t.timing.FirstByte = time.Since(t.start)
+		},
+	}
+	return req.WithContext(httptrace.WithClientTrace(req.Context(), trace)), t
+}
+
+// result returns the phases measured so far
+func (t *timingTrace) result() Timing {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.timing
+}
@@ -0,0 +1,641 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"crypto/rand"
+	"crypto/sha1"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+	"unicode/utf8"
+)
+
+// RequestKind selects the protocol of a request
+type RequestKind string
+
+const (
+	KindHTTP      RequestKind = ""          // Plain HTTP request
+	KindWebSocket RequestKind = "websocket" // WebSocket connection driven by a send/expect script
+)
+
+// webSocketMethod is shown instead of the HTTP method for WebSocket requests
+const webSocketMethod = "WS"
+
+// methodLabel returns the method shown for the request, e.g. in the project tree
+func (request *Request) methodLabel() string {
+	if request.Kind == KindWebSocket {
+		return webSocketMethod
+	}
+	return request.Method
+}
+
+// NewWebSocketRequest creates a new WebSocket request with default values
+func (project *Project) NewWebSocketRequest() *Request {
+	return &Request{
+		ID:          newRequestID(),
+		Name:        "WebSocket",
+		Kind:        KindWebSocket,
+		Host:        project.getDefaultHost(),
+		Method:      "GET",
+		Headers:     Params{},
+		QueryParams: Params{},
+	}
+}
+
+// WebSocketAction is the action of a script step
+type WebSocketAction string
+
+const (
+	WebSocketSend       WebSocketAction = "send"        // Send Data as text message
+	WebSocketSendBinary WebSocketAction = "send-binary" // Send Data, hex encoded, as binary message
+	WebSocketExpect     WebSocketAction = "expect"      // The next received message must contain Data, or match it if written as /regex/
+	WebSocketWait       WebSocketAction = "wait"        // Pause for Data milliseconds
+)
+
+// WebSocketStep is a step of the script run after connecting
+type WebSocketStep struct {
+	Action   WebSocketAction `json:"action"`
+	Data     string          `json:"data,omitempty"`
+	Disabled bool            `json:"disabled,omitempty"`
+}
+
+// FormatWebSocketSteps renders one "action: data" line per step, disabled steps with a '#' prefix
+func FormatWebSocketSteps(steps []WebSocketStep) string {
+	params := make(Params, 0, len(steps))
+	for _, step := range steps {
+		params = append(params, Param{Name: string(step.Action), Value: step.Data, Disabled: step.Disabled})
+	}
+	return params.Format()
+}
+
+// ParseWebSocketSteps parses the lines written by FormatWebSocketSteps
+func ParseWebSocketSteps(input string) []WebSocketStep {
+	var steps []WebSocketStep
+	for _, param := range ParseParams(input) {
+		steps = append(steps, WebSocketStep{Action: WebSocketAction(strings.ToLower(param.Name)), Data: param.Value, Disabled: param.Disabled})
+	}
+	return steps
+}
+
+// WebSocket opcodes (RFC 6455, section 5.2)
+const (
+	wsOpContinuation = 0x0
+	wsOpText         = 0x1
+	wsOpBinary       = 0x2
+	wsOpClose        = 0x8
+	wsOpPing         = 0x9
+	wsOpPong         = 0xA
+)
+
+// wsMaxMessageSize protects against runaway messages
+const wsMaxMessageSize = 64 << 20
+
+// wsMaxTranscriptEntries limits the transcript of long running connections, older entries are dropped
+const wsMaxTranscriptEntries = 10000
+
+// WebSocketEntry is a line of the session transcript
+type WebSocketEntry struct {
+	Time      time.Time
+	Direction string // "→" sent, "←" received, "•" connection events and script results
+	Binary    bool
+	Data      []byte
+}
+
+// String formats the entry as transcript line
+func (entry WebSocketEntry) String() string {
+	text := string(entry.Data)
+	if entry.Binary {
+		preview := entry.Data[:min(len(entry.Data), 64)]
+		text = fmt.Sprintf("[binary %d bytes] %s", len(entry.Data), hex.EncodeToString(preview))
+		if len(preview) < len(entry.Data) {
+			text += "…"
+		}
+	}
+	return fmt.Sprintf("%s %s %s", entry.Time.Format("15:04:05.000"), entry.Direction, text)
+}
+
+// WebSocketSession is an open WebSocket connection with its transcript
+type WebSocketSession struct {
+	URL          string
+	Response     *http.Response // Handshake response
+	Opened       time.Time
+	conn         io.ReadWriteCloser
+	reader       *bufio.Reader
+	cancel       context.CancelFunc
+	stopOnCancel func() bool // Stops closing the connection when the context of openWebSocket is cancelled
+	onEntry      func(WebSocketEntry)
+	writeMu      sync.Mutex
+	closeOnce    sync.Once
+	done         chan struct{}
+
+	mu        sync.Mutex
+	entries   []WebSocketEntry
+	dropped   int              // Entries dropped from the start of the transcript
+	received  []WebSocketEntry // Received data messages, consumed by expect steps
+	expected  int              // Index of the next received message an expect step looks at
+	changed   chan struct{}    // Closed and replaced whenever a message arrives
+	closeCode int
+	closeText string
+	err       error
+	failures  []string
+}
+
+// webSocketURLs returns the http(s) URL used for the handshake and the ws(s) URL shown to the user
+func webSocketURLs(rawURL string) (string, string, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return "", "", err
+	}
+	switch u.Scheme {
+	case "http", "ws":
+		u.Scheme = "http"
+	case "https", "wss":
+		u.Scheme = "https"
+	default:
+		return "", "", fmt.Errorf("unsupported WebSocket URL scheme %q", u.Scheme)
+	}
+	httpURL := u.String()
+	u.Scheme = strings.Replace(u.Scheme, "http", "ws", 1)
+	return httpURL, u.String(), nil
+}
+
+// openWebSocket connects to the WebSocket endpoint of the request with its headers,
+// the TLS and proxy settings, and the cookies of the environment.
+// onEntry is called from the connection goroutines for every transcript entry.
+// Cancelling ctx aborts the handshake and ends the connection.
+func (request *Request) openWebSocket(ctx context.Context, options SendOptions, onEntry func(WebSocketEntry)) (*WebSocketSession, error) {
+	requestURL := request.Host + options.Path
+	if query := request.QueryParams.EncodeQuery(request.QueryEncoding); query != "" {
+		separator := "?"
+		if strings.Contains(requestURL, "?") {
+			separator = "&"
+		}
+		requestURL += separator + query
+	}
+	httpURL, wsURL, err := webSocketURLs(requestURL)
+	if err != nil {
+		return nil, err
+	}
+
+	// The handshake timeout must not end the connection once it is upgraded
+	connCtx, cancel := context.WithCancel(ctx)
+	req, err := http.NewRequestWithContext(connCtx, "GET", httpURL, nil)
+	if err != nil {
+		cancel()
+		return nil, err
+	}
+	for _, header := range request.Headers {
+		name := strings.TrimSpace(header.Name)
+		// The body headers of the HTTP defaults make no sense for the handshake
+		if name != "" && !header.Disabled && !strings.EqualFold(name, "Content-Type") {
+			req.Header.Add(name, strings.TrimSpace(header.Value))
+		}
+	}
+	if auth := request.Auth; auth != nil && auth.Type == AuthJWT && auth.JWT != nil {
+		token, err := auth.JWT.mint(time.Now())
+		if err != nil {
+			cancel()
+			return nil, err
+		}
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	if options.Jar != nil {
+		for _, cookie := range options.Jar.Cookies(req.URL) {
+			req.AddCookie(cookie)
+		}
+	}
+	keyBytes := make([]byte, 16)
+	if _, err := rand.Read(keyBytes); err != nil {
+		cancel()
+		return nil, err
+	}
+	key := base64.StdEncoding.EncodeToString(keyBytes)
+	req.Header.Set("Connection", "Upgrade")
+	req.Header.Set("Upgrade", "websocket")
+	req.Header.Set("Sec-WebSocket-Version", "13")
+	req.Header.Set("Sec-WebSocket-Key", key)
+
+	// The transport is used directly, as the client timeout would also limit the connection lifetime
+	client, err := options.Settings.httpClient(options.Environment, options.TimeoutInMs, nil, options.FreshConnection)
+	if err != nil {
+		cancel()
+		return nil, err
+	}
+	timeout := time.Duration(options.TimeoutInMs) * time.Millisecond
+	var timedOut atomic.Bool
+	timer := time.AfterFunc(timeout, func() {
+		timedOut.Store(true)
+		cancel()
+	})
+	resp, err := client.Transport.RoundTrip(req)
+	timer.Stop()
+	if err == nil && (timedOut.Load() || ctx.Err() != nil) {
+		resp.Body.Close()
+		err = connCtx.Err()
+	}
+	if err != nil {
+		cancel()
+		switch {
+		case ctx.Err() != nil:
+			return nil, ErrCancelled
+		case timedOut.Load():
+			return nil, fmt.Errorf("WebSocket handshake timed out after %v", timeout)
+		}
+		return nil, err
+	}
+	if options.Jar != nil {
+		options.Jar.SetCookies(req.URL, resp.Cookies())
+	}
+
+	conn, ok := resp.Body.(io.ReadWriteCloser)
+	if resp.StatusCode != http.StatusSwitchingProtocols || !ok {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+		resp.Body.Close()
+		cancel()
+		return nil, fmt.Errorf("WebSocket handshake failed: %s %s", resp.Status, strings.TrimSpace(string(body)))
+	}
+	accept := sha1.Sum([]byte(key + "258EAFA5-E914-47DA-95CA-C5AB0DC85B11"))
+	if resp.Header.Get("Sec-WebSocket-Accept") != base64.StdEncoding.EncodeToString(accept[:]) {
+		conn.Close()
+		cancel()
+		return nil, fmt.Errorf("WebSocket handshake failed: invalid Sec-WebSocket-Accept")
+	}
+
+	session := &WebSocketSession{
+		URL:      wsURL,
+		Response: resp,
+		Opened:   time.Now(),
+		conn:     conn,
+		reader:   bufio.NewReader(conn),
+		cancel:   cancel,
+		onEntry:  onEntry,
+		done:     make(chan struct{}),
+		changed:  make(chan struct{}),
+	}
+	session.log("•", false, []byte("Connected to "+wsURL))
+	session.stopOnCancel = context.AfterFunc(ctx, session.Close)
+	go session.readLoop()
+	return session, nil
+}
+
+// log appends an entry to the transcript
+func (session *WebSocketSession) log(direction string, binary bool, data []byte) WebSocketEntry {
+	entry := WebSocketEntry{Time: time.Now(), Direction: direction, Binary: binary, Data: data}
+	session.mu.Lock()
+	if len(session.entries) >= wsMaxTranscriptEntries {
+		drop := wsMaxTranscriptEntries / 10
+		session.entries = slices.Delete(session.entries, 0, drop)
+		session.dropped += drop
+	}
+	session.entries = append(session.entries, entry)
+	if direction == "←" {
+		if len(session.received) >= wsMaxTranscriptEntries {
+			// Messages already checked by expect steps go first, then the oldest unchecked ones
+			drop := max(session.expected, wsMaxTranscriptEntries/10)
+			session.received = slices.Delete(session.received, 0, drop)
+			session.expected = max(session.expected-drop, 0)
+		}
+		session.received = append(session.received, entry)
+		close(session.changed)
+		session.changed = make(chan struct{})
+	}
+	session.mu.Unlock()
+	if session.onEntry != nil {
+		session.onEntry(entry)
+	}
+	return entry
+}
+
+// readLoop receives frames until the connection closes, answering pings and close frames
+func (session *WebSocketSession) readLoop() {
+	defer close(session.done)
+	defer session.stopOnCancel()
+	defer session.cancel()
+	defer session.conn.Close()
+
+	var message []byte
+	var messageOpcode byte
+	for {
+		fin, opcode, payload, err := readWebSocketFrame(session.reader)
+		if err != nil {
+			session.mu.Lock()
+			closed := session.closeCode != 0
+			if !closed {
+				session.err = err
+			}
+			session.mu.Unlock()
+			if !closed {
+				session.log("•", false, []byte("Connection lost: "+err.Error()))
+			}
+			return
+		}
+
+		switch opcode {
+		case wsOpPing:
+			session.writeFrame(wsOpPong, payload)
+		case wsOpPong:
+		case wsOpClose:
+			code, text := 1005, ""
+			if len(payload) >= 2 {
+				code = int(binary.BigEndian.Uint16(payload))
+				text = string(payload[2:])
+			}
+			session.mu.Lock()
+			replied := session.closeCode != 0
+			session.closeCode, session.closeText = code, text
+			session.mu.Unlock()
+			if !replied {
+				// Echo the close frame of the server to complete the closing handshake
+				session.writeFrame(wsOpClose, payload[:min(len(payload), 2)])
+			}
+			session.log("•", false, fmt.Appendf(nil, "Closed: %d %s", code, text))
+			return
+		case wsOpContinuation, wsOpText, wsOpBinary:
+			if opcode != wsOpContinuation {
+				message, messageOpcode = nil, opcode
+			}
+			if len(message)+len(payload) > wsMaxMessageSize {
+				session.mu.Lock()
+				session.err = fmt.Errorf("message exceeds %s", formatSize(wsMaxMessageSize))
+				session.mu.Unlock()
+				session.log("•", false, []byte("Connection aborted: message too large"))
+				return
+			}
+			message = append(message, payload...)
+			if fin {
+				session.log("←", messageOpcode == wsOpBinary || !utf8.Valid(message), message)
+				message = nil
+			}
+		}
+	}
+}
+
+// readWebSocketFrame reads a single frame; frames from the server are never masked
+func readWebSocketFrame(reader *bufio.Reader) (bool, byte, []byte, error) {
+	header := make([]byte, 2)
+	if _, err := io.ReadFull(reader, header); err != nil {
+		return false, 0, nil, err
+	}
+	fin := header[0]&0x80 != 0
+	opcode := header[0] & 0x0F
+	masked := header[1]&0x80 != 0
+	length := uint64(header[1] & 0x7F)
+	switch length {
+	case 126:
+		extended := make([]byte, 2)
+		if _, err := io.ReadFull(reader, extended); err != nil {
+			return false, 0, nil, err
+		}
+		length = uint64(binary.BigEndian.Uint16(extended))
+	case 127:
+		extended := make([]byte, 8)
+		if _, err := io.ReadFull(reader, extended); err != nil {
+			return false, 0, nil, err
+		}
+		length = binary.BigEndian.Uint64(extended)
+	}
+	if length > wsMaxMessageSize {
+		return false, 0, nil, fmt.Errorf("frame of %d bytes exceeds %s", length, formatSize(wsMaxMessageSize))
+	}
+	var mask []byte
+	if masked {
+		mask = make([]byte, 4)
+		if _, err := io.ReadFull(reader, mask); err != nil {
+			return false, 0, nil, err
+		}
+	}
+	payload := make([]byte, length)
+	if _, err := io.ReadFull(reader, payload); err != nil {
+		return false, 0, nil, err
+	}
+	for i := range mask {
+		for j := i; j < len(payload); j += 4 {
+			payload[j] ^= mask[i]
+		}
+	}
+	return fin, opcode, payload, nil
+}
+
+// writeFrame sends a single masked frame, as required for clients
+func (session *WebSocketSession) writeFrame(opcode byte, payload []byte) error {
+	frame := []byte{0x80 | opcode}
+	switch length := len(payload); {
+	case length < 126:
+		frame = append(frame, 0x80|byte(length))
+	case length <= 0xFFFF:
+		frame = binary.BigEndian.AppendUint16(append(frame, 0x80|126), uint16(length))
+	default:
+		frame = binary.BigEndian.AppendUint64(append(frame, 0x80|127), uint64(length))
+	}
+	mask := make([]byte, 4)
+	if _, err := rand.Read(mask); err != nil {
+		return err
+	}
+	frame = append(frame, mask...)
+	start := len(frame)
+	frame = append(frame, payload...)
+	for i := range payload {
+		frame[start+i] ^= mask[i%4]
+	}
+
+	session.writeMu.Lock()
+	defer session.writeMu.Unlock()
+	_, err := session.conn.Write(frame)
+	return err
+}
+
+// SendText sends a text message
+func (session *WebSocketSession) SendText(text string) error {
+	if err := session.writeFrame(wsOpText, []byte(text)); err != nil {
+		return err
+	}
+	session.log("→", false, []byte(text))
+	return nil
+}
+
+// SendBinary sends a binary message
+func (session *WebSocketSession) SendBinary(data []byte) error {
+	if err := session.writeFrame(wsOpBinary, data); err != nil {
+		return err
+	}
+	session.log("→", true, data)
+	return nil
+}
+
+// Close starts the closing handshake and waits briefly for the server to answer
+func (session *WebSocketSession) Close() {
+	session.closeOnce.Do(func() {
+		session.mu.Lock()
+		alreadyClosed := session.closeCode != 0
+		if !alreadyClosed {
+			session.closeCode = 1000
+		}
+		session.mu.Unlock()
+		if !alreadyClosed {
+			session.writeFrame(wsOpClose, binary.BigEndian.AppendUint16(nil, 1000))
+			session.log("•", false, []byte("Closing connection"))
+		}
+		select {
+		case <-session.done:
+		case <-time.After(2 * time.Second):
+			session.conn.Close()
+			<-session.done
+		}
+	})
+}
+
+// Done is closed when the connection has ended
+func (session *WebSocketSession) Done() <-chan struct{} {
+	return session.done
+}
+
+// expect waits up to timeout for the next received message and checks it against pattern.
+// A pattern written as /regex/ is matched as regular expression, otherwise it must be contained.
+func (session *WebSocketSession) expect(pattern string, timeout time.Duration) error {
+	deadline := time.After(timeout)
+	for {
+		session.mu.Lock()
+		if session.expected < len(session.received) {
+			message := session.received[session.expected]
+			session.expected++
+			session.mu.Unlock()
+			return matchWebSocketMessage(pattern, message)
+		}
+		changed := session.changed
+		session.mu.Unlock()
+
+		select {
+		case <-changed:
+		case <-session.done:
+			return fmt.Errorf("expected %q, but the connection closed", pattern)
+		case <-deadline:
+			return fmt.Errorf("expected %q, but nothing was received within %v", pattern, timeout)
+		}
+	}
+}
+
+func matchWebSocketMessage(pattern string, message WebSocketEntry) error {
+	text := string(message.Data)
+	if message.Binary {
+		text = hex.EncodeToString(message.Data)
+	}
+	if len(pattern) >= 2 && strings.HasPrefix(pattern, "/") && strings.HasSuffix(pattern, "/") {
+		re, err := regexp.Compile(pattern[1 : len(pattern)-1])
+		if err != nil {
+			return fmt.Errorf("invalid pattern %s: %v", pattern, err)
+		}
+		if !re.MatchString(text) {
+			return fmt.Errorf("expected match of %s, got %q", pattern, text)
+		}
+		return nil
+	}
+	if !strings.Contains(text, pattern) {
+		return fmt.Errorf("expected %q, got %q", pattern, text)
+	}
+	return nil
+}
+
+// RunScript executes the enabled steps in order and stops at the first failure,
+// which is recorded in the transcript and returned. Expect steps wait up to timeout.
+func (session *WebSocketSession) RunScript(steps []WebSocketStep, timeout time.Duration) error {
+	for i, step := range steps {
+		if step.Disabled {
+			continue
+		}
+		var err error
+		switch step.Action {
+		case WebSocketSend:
+			err = session.SendText(step.Data)
+		case WebSocketSendBinary:
+			var data []byte
+			if data, err = hex.DecodeString(strings.ReplaceAll(step.Data, " ", "")); err == nil {
+				err = session.SendBinary(data)
+			}
+		case WebSocketExpect:
+			if err = session.expect(step.Data, timeout); err == nil {
+				session.log("•", false, []byte("✓ expect "+step.Data))
+			}
+		case WebSocketWait:
+			var ms int
+			if ms, err = strconv.Atoi(strings.TrimSpace(step.Data)); err == nil {
+				select {
+				case <-time.After(time.Duration(ms) * time.Millisecond):
+				case <-session.done:
+				}
+			}
+		default:
+			err = fmt.Errorf("unknown action %q", step.Action)
+		}
+		if err != nil {
+			failure := fmt.Sprintf("step %d (%s): %v", i+1, step.Action, err)
+			session.mu.Lock()
+			session.failures = append(session.failures, failure)
+			session.mu.Unlock()
+			session.log("•", false, []byte("✗ "+failure))
+			return fmt.Errorf("%s", failure)
+		}
+	}
+	return nil
+}
+
+// Transcript returns all entries, one line each
+func (session *WebSocketSession) Transcript() string {
+	session.mu.Lock()
+	defer session.mu.Unlock()
+	var builder strings.Builder
+	if session.dropped > 0 {
+		fmt.Fprintf(&builder, "… %d earlier entries dropped\r\n", session.dropped)
+	}
+	for _, entry := range session.entries {
+		builder.WriteString(entry.String())
+		builder.WriteString("\r\n")
+	}
+	return builder.String()
+}
+
+// ResponseData summarizes the session as response, with the transcript as body
+func (session *WebSocketSession) ResponseData() *ResponseData {
+	transcript := session.Transcript()
+	session.mu.Lock()
+	defer session.mu.Unlock()
+	status := "WebSocket open"
+	switch {
+	case session.err != nil:
+		status = "WebSocket error"
+	case session.closeCode != 0:
+		status = fmt.Sprintf("WebSocket closed (%d)", session.closeCode)
+	}
+	return &ResponseData{
+		Text:       transcript,
+		Headers:    ParamsFromHeader(session.Response.Header),
+		StatusCode: session.Response.StatusCode,
+		Status:     status,
+		Duration:   time.Since(session.Opened),
+		Timestamp:  time.Now(),
+		Failures:   append([]string(nil), session.failures...),
+	}
+}
+
+// runWebSocket connects, runs the script of the request, closes the connection
+// and returns the transcript, with failed expectations as failures
+func (request *Request) runWebSocket(ctx context.Context, options SendOptions) (*ResponseData, error) {
+	session, err := request.openWebSocket(ctx, options, nil)
+	if err != nil {
+		return nil, err
+	}
+	session.RunScript(request.WebSocketSteps, time.Duration(options.TimeoutInMs)*time.Millisecond)
+	session.Close()
+	return session.ResponseData(), nil
+}
@@ -180,6 +180,15 @@ func (control *Control) SetText(text string) bool {
 	return ret != 0
 }
 
+// AppendText adds text at the end of an edit control without replacing its content
+func (control *Control) AppendText(text string) {
+	// Appending is subject to the text limit of the control, which SetText ignores
+	sendMessage(control.Hwnd, EM_SETLIMITTEXT, 0, 0)
+	length, _, _ := procGetWindowTextLengthW.Call(uintptr(control.Hwnd))
+	sendMessage(control.Hwnd, EM_SETSEL, length, length)
+	sendMessage(control.Hwnd, EM_REPLACESEL, 0, uintptr(unsafe.Pointer(StringToUTF16Ptr(text))))
+}
+
 func (control *Control) SetReadOnly(readonly bool) {
 	wParam := uintptr(0)
 	if readonly {
@@ -298,8 +298,10 @@ const (
 	LVM_GETITEMTEXTW             = 0x1073
 
 	// Edit control messages
-	EM_SETSEL      = 0x00B1
-	EM_SETREADONLY = 0x00CF
+	EM_SETSEL       = 0x00B1
+	EM_REPLACESEL   = 0x00C2
+	EM_SETLIMITTEXT = 0x00C5
+	EM_SETREADONLY  = 0x00CF
 
 	// Virtual key codes
 	VK_RETURN = 0x0D